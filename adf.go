@@ -0,0 +1,212 @@
+package jiraattach
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Comment formats accepted by comment_format.
+const (
+	commentFormatAuto = "auto"
+	commentFormatWiki = "wiki"
+	commentFormatADF  = "adf"
+)
+
+// adfNode is a node of an Atlassian Document Format document.
+type adfNode struct {
+	Type    string                 `json:"type"`
+	Version int                    `json:"version,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Content []*adfNode             `json:"content,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Marks   []adfMark              `json:"marks,omitempty"`
+}
+
+// adfMark formats a text node, such as making it bold or a link.
+type adfMark struct {
+	Type  string            `json:"type"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+var (
+	wikiHeading = regexp.MustCompile(`^h([1-6])\. (.*)$`)
+	// wikiInline matches the inline markup jiraattach writes in comments:
+	// attachment links, links, embedded images, monospace, bold and the
+	// (/) and (x) icons.
+	// wikiImages matches a line of nothing but embedded images.
+	wikiImages = regexp.MustCompile(`^\s*(?:![^|!\s]+(?:\|[^!]*)?!\s*)+$`)
+	wikiImage  = regexp.MustCompile(`!([^|!\s]+)(?:\|[^!]*)?!`)
+	wikiInline = regexp.MustCompile(`\[\^([^\]]+)\]|\[([^|\]]+)\|([^\]]+)\]|!([^|!\s]+)(?:\|[^!]*)?!|\{\{(.+?)\}\}|\*([^*\s][^*]*)\*|\(/\)|\(x\)`)
+)
+
+// wikiToADF converts a comment written in the wiki markup jiraattach uses,
+// paragraphs, h1. to h6. headings and * bullet lists, into an ADF document.
+// Attachment links to filenames found in links point at their URLs, and
+// other attachment links become plain text since ADF can only embed
+// attachments through the media API. A line of nothing but embedded images
+// found in links shows them as external media, which the browser loads from
+// Jira like any attachment.
+func wikiToADF(body string, links map[string]string) *adfNode {
+	doc := &adfNode{Type: "doc", Version: 1}
+	var para, list *adfNode
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		if strings.TrimSpace(line) == "" {
+			para, list = nil, nil
+			continue
+		}
+		if m := wikiHeading.FindStringSubmatch(line); m != nil {
+			level, _ := strconv.Atoi(m[1])
+			doc.Content = append(doc.Content, &adfNode{
+				Type:    "heading",
+				Attrs:   map[string]interface{}{"level": level},
+				Content: wikiInlineToADF(m[2], links),
+			})
+			para, list = nil, nil
+			continue
+		}
+		if media := wikiMediaToADF(line, links); media != nil {
+			doc.Content = append(doc.Content, media...)
+			para, list = nil, nil
+			continue
+		}
+		if strings.HasPrefix(line, "* ") {
+			if list == nil {
+				list = &adfNode{Type: "bulletList"}
+				doc.Content = append(doc.Content, list)
+			}
+			list.Content = append(list.Content, &adfNode{
+				Type:    "listItem",
+				Content: []*adfNode{{Type: "paragraph", Content: wikiInlineToADF(line[2:], links)}},
+			})
+			para = nil
+			continue
+		}
+		if para == nil {
+			para = &adfNode{Type: "paragraph"}
+			doc.Content = append(doc.Content, para)
+		} else {
+			para.Content = append(para.Content, &adfNode{Type: "hardBreak"})
+		}
+		para.Content = append(para.Content, wikiInlineToADF(line, links)...)
+		list = nil
+	}
+	return doc
+}
+
+// wikiMediaToADF converts a line of embedded images into ADF media blocks,
+// or returns nil when the line has anything else or an image not in links.
+func wikiMediaToADF(line string, links map[string]string) []*adfNode {
+	if !wikiImages.MatchString(line) {
+		return nil
+	}
+	var nodes []*adfNode
+	for _, m := range wikiImage.FindAllStringSubmatch(line, -1) {
+		u, ok := links[m[1]]
+		if !ok {
+			return nil
+		}
+		nodes = append(nodes, &adfNode{
+			Type:  "mediaSingle",
+			Attrs: map[string]interface{}{"layout": "center"},
+			Content: []*adfNode{{
+				Type:  "media",
+				Attrs: map[string]interface{}{"type": "external", "url": u},
+			}},
+		})
+	}
+	return nodes
+}
+
+// wikiInlineToADF converts the inline markup of a line into ADF text nodes.
+func wikiInlineToADF(line string, links map[string]string) []*adfNode {
+	var nodes []*adfNode
+	text := func(s string, marks ...adfMark) {
+		if s != "" {
+			nodes = append(nodes, &adfNode{Type: "text", Text: s, Marks: marks})
+		}
+	}
+	attachment := func(name string) {
+		if u, ok := links[name]; ok {
+			text(name, adfMark{Type: "link", Attrs: map[string]string{"href": u}})
+		} else {
+			text(name)
+		}
+	}
+	last := 0
+	for _, m := range wikiInline.FindAllStringSubmatchIndex(line, -1) {
+		text(line[last:m[0]])
+		last = m[1]
+		group := func(i int) string {
+			if m[2*i] < 0 {
+				return ""
+			}
+			return line[m[2*i]:m[2*i+1]]
+		}
+		switch match := line[m[0]:m[1]]; {
+		case m[2] >= 0:
+			attachment(group(1))
+		case m[4] >= 0:
+			text(group(2), adfMark{Type: "link", Attrs: map[string]string{"href": group(3)}})
+		case m[8] >= 0:
+			attachment(group(4))
+		case m[10] >= 0:
+			text(group(5), adfMark{Type: "code"})
+		case m[12] >= 0:
+			text(group(6), adfMark{Type: "strong"})
+		case match == "(/)":
+			nodes = append(nodes, &adfNode{Type: "emoji", Attrs: map[string]interface{}{"shortName": ":white_check_mark:", "text": "✅"}})
+		default:
+			nodes = append(nodes, &adfNode{Type: "emoji", Attrs: map[string]interface{}{"shortName": ":x:", "text": "❌"}})
+		}
+	}
+	text(line[last:])
+	return nodes
+}
+
+// useADF reports whether comments should be posted with version 3 of the
+// API in ADF, as comment_format asks or, by default, when the instance is
+// Jira Cloud.
+func (c *client) useADF() bool {
+	switch c.commentFormat {
+	case commentFormatWiki:
+		return false
+	case commentFormatADF:
+		return true
+	}
+	caps, err := c.capabilities()
+	if err != nil {
+		warnf("posting comment as wiki markup: %v", err)
+		return false
+	}
+	return caps.ADF
+}
+
+// postADFComment converts body from wiki markup to ADF and posts it with
+// version 3 of the API.
+func (c *client) postADFComment(key, body string, opts commentOptions) (*Comment, error) {
+	links := map[string]string{}
+	if strings.Contains(body, "[^") || strings.Contains(body, "!") {
+		attachments, err := c.attachments(key)
+		if err != nil {
+			warnf("unable to link attachments in comment: %v", err)
+		}
+		for _, a := range attachments {
+			links[a.Filename] = c.attachmentURL(a)
+		}
+	}
+	comment, err := c.api.AddADFComment(c.context(), key, wikiToADF(body, links), opts.jira())
+	if err != nil {
+		return nil, err
+	}
+	// The body comes back as ADF, so it is replaced with the text posted.
+	comment.Body = body
+	return comment, nil
+}
+
+// attachmentURL returns the link to the attachment on the site, which opens
+// in a browser however the client reaches the API.
+func (c *client) attachmentURL(a Attachment) string {
+	return c.siteURL + "/secure/attachment/" + url.PathEscape(a.ID) + "/" + url.PathEscape(a.Filename)
+}
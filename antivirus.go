@@ -0,0 +1,134 @@
+package jiraattach
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// AntivirusConfig configures scanning of attachments with ClamAV before
+// they are uploaded. Scanning is enabled when either Clamd or Clamscan is
+// set, with Clamd taking precedence.
+type AntivirusConfig struct {
+	// Clamd is the address of a clamd daemon, either a unix socket path or a
+	// host:port TCP address.
+	Clamd string `json:"clamd"`
+
+	// Clamscan is the path to the clamscan executable.
+	Clamscan string `json:"clamscan"`
+
+	// Action is what to do with infected files, block (the default) to
+	// refuse the upload or warn to upload anyway after printing a warning.
+	Action string `json:"action"`
+}
+
+func (c *AntivirusConfig) enabled() bool {
+	return c != nil && (c.Clamd != "" || c.Clamscan != "")
+}
+
+// check scans r and returns an error if it is infected and the configured
+// action is to block it.
+func (c *AntivirusConfig) check(filename string, r io.Reader) error {
+	var (
+		virus string
+		err   error
+	)
+	if c.Clamd != "" {
+		virus, err = clamdScan(c.Clamd, r)
+	} else {
+		virus, err = clamscan(c.Clamscan, r)
+	}
+	if err != nil {
+		return fmt.Errorf("error scanning %v for viruses: %w", filename, err)
+	}
+	if virus == "" {
+		return nil
+	}
+	if c.Action == "warn" {
+		warnf("%v is infected with %v", filename, virus)
+		return nil
+	}
+	return fmt.Errorf("refusing to attach %v, it is infected with %v", filename, virus)
+}
+
+// clamdScan streams r to clamd using the INSTREAM command and returns the
+// name of the virus found, if any.
+func clamdScan(addr string, r io.Reader) (string, error) {
+	network := "tcp"
+	if strings.HasPrefix(addr, "/") || strings.HasPrefix(addr, "unix:") {
+		network, addr = "unix", strings.TrimPrefix(addr, "unix:")
+	}
+	conn, err := net.DialTimeout(network, addr, 5*time.Second)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	w := bufio.NewWriter(conn)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", err
+	}
+	chunk := make([]byte, 32*1024)
+	size := make([]byte, 4)
+	for {
+		n, rerr := r.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			w.Write(size)
+			if _, err := w.Write(chunk[:n]); err != nil {
+				return "", err
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return "", rerr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	w.Write(size)
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	// Replies look like "stream: OK" or "stream: Eicar-Signature FOUND".
+	result := strings.TrimSpace(strings.TrimPrefix(string(bytes.TrimRight(reply, "\x00")), "stream:"))
+	switch {
+	case result == "OK":
+		return "", nil
+	case strings.HasSuffix(result, " FOUND"):
+		return strings.TrimSuffix(result, " FOUND"), nil
+	}
+	return "", fmt.Errorf("clamd: %v", result)
+}
+
+// clamscan scans r by piping it to the clamscan executable and returns the
+// name of the virus found, if any.
+func clamscan(path string, r io.Reader) (string, error) {
+	cmd := exec.Command(path, "--no-summary", "--infected", "-")
+	cmd.Stdin = r
+	out, err := cmd.Output()
+	if err == nil {
+		return "", nil
+	}
+	// clamscan exits with 1 when a virus is found, reporting it as
+	// "stdin: Eicar-Signature FOUND".
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 {
+		result := strings.TrimSpace(string(out))
+		result = strings.TrimPrefix(result, "stdin:")
+		return strings.TrimSpace(strings.TrimSuffix(result, "FOUND")), nil
+	}
+	return "", fmt.Errorf("clamscan: %w", err)
+}
@@ -0,0 +1,291 @@
+package jiraattach
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// archiveExtensions maps the -archive formats to the extension given to
+// their archives.
+var archiveExtensions = map[string]string{
+	"zip":    ".zip",
+	"tar.gz": ".tar.gz",
+}
+
+// fileFilter selects the files under a directory to attach, by glob
+// patterns matched against each file's slash separated path relative to
+// the directory and against its name. A directory that is excluded is
+// skipped along with everything in it.
+type fileFilter struct {
+	include []string
+	exclude []string
+}
+
+func (f fileFilter) validate() error {
+	for _, pattern := range append(append([]string(nil), f.include...), f.exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return usageErrorf("invalid pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// selects reports whether the file at rel, relative to the directory, is
+// attached.
+func (f fileFilter) selects(rel string) bool {
+	if matchAny(f.exclude, rel) {
+		return false
+	}
+	return len(f.include) == 0 || matchAny(f.include, rel)
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoreFile lists glob patterns, one per line, for files to leave out of a
+// directory and those under it, as for -exclude. Blank lines and lines
+// starting with # are skipped.
+const ignoreFile = ".jiraattachignore"
+
+// ignoreRules are the patterns of an ignore file in the directory at dir,
+// relative to the directory being walked.
+type ignoreRules struct {
+	dir      string
+	patterns []string
+}
+
+// ignored reports whether the ignore rules leave out rel.
+func (r ignoreRules) ignored(rel string) bool {
+	if r.dir != "" {
+		if !strings.HasPrefix(rel, r.dir+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.dir+"/")
+	}
+	return matchAny(r.patterns, rel)
+}
+
+// readIgnoreFile returns the patterns in the ignore file in dir, if any.
+func readIgnoreFile(dir string) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ignoreFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var patterns []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.Trim(line, "/")
+		if _, err := path.Match(line, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q in %v: %w", line, filepath.Join(dir, ignoreFile), err)
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns, nil
+}
+
+// walkFiles returns the regular files under dir that f and any ignore
+// files select, as sorted slash separated paths relative to dir.
+func walkFiles(dir string, f fileFilter) ([]string, error) {
+	var (
+		files []string
+		rules []ignoreRules
+	)
+	ignored := func(rel string) bool {
+		for _, r := range rules {
+			if r.ignored(rel) {
+				return true
+			}
+		}
+		return false
+	}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel == "." {
+				rel = ""
+			} else if matchAny(f.exclude, rel) || ignored(rel) {
+				return filepath.SkipDir
+			}
+			patterns, err := readIgnoreFile(p)
+			if err != nil {
+				return err
+			}
+			if len(patterns) > 0 {
+				rules = append(rules, ignoreRules{dir: rel, patterns: patterns})
+			}
+			return nil
+		}
+		if info.Mode().IsRegular() && path.Base(rel) != ignoreFile && !ignored(rel) && f.selects(rel) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading directory, %v: %w", dir, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// expandDirs replaces the directories among paths with the files under
+// them that f and any ignore files select, for -recursive.
+func expandDirs(paths []string, f fileFilter) ([]string, error) {
+	var expanded []string
+	for _, p := range paths {
+		if p == "-" || !isDir(p) {
+			expanded = append(expanded, p)
+			continue
+		}
+		files, err := walkFiles(p, f)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files to attach in %v", p)
+		}
+		for _, rel := range files {
+			expanded = append(expanded, filepath.Join(p, filepath.FromSlash(rel)))
+		}
+	}
+	return expanded, nil
+}
+
+// isDir reports whether path names a directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// archiveName returns the name of the archive of the directory at dir.
+func archiveName(dir, format string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return filepath.Base(dir) + archiveExtensions[format]
+}
+
+// archiveDir returns a reader streaming an archive of the files under dir
+// that f selects, which is built as it is read rather than written to disk
+// first. Closing the reader stops building it.
+func archiveDir(dir, format string, f fileFilter) (io.ReadCloser, error) {
+	files, err := walkFiles(dir, f)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files to attach in %v", dir)
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeArchive(pw, format, dir, files))
+	}()
+	return pr, nil
+}
+
+// writeArchive writes the files, given relative to dir, to w as an archive
+// in format.
+func writeArchive(w io.Writer, format, dir string, files []string) error {
+	switch format {
+	case "zip":
+		zw := zip.NewWriter(w)
+		for _, rel := range files {
+			err := copyInto(dir, rel, func(info os.FileInfo) (io.Writer, error) {
+				header, err := zip.FileInfoHeader(info)
+				if err != nil {
+					return nil, err
+				}
+				header.Name = rel
+				header.Method = zip.Deflate
+				return zw.CreateHeader(header)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return zw.Close()
+	case "tar.gz":
+		gw := gzip.NewWriter(w)
+		tw := tar.NewWriter(gw)
+		for _, rel := range files {
+			err := copyInto(dir, rel, func(info os.FileInfo) (io.Writer, error) {
+				header, err := tar.FileInfoHeader(info, "")
+				if err != nil {
+					return nil, err
+				}
+				header.Name = rel
+				return tw, tw.WriteHeader(header)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if err := tw.Close(); err != nil {
+			return err
+		}
+		return gw.Close()
+	}
+	return fmt.Errorf("invalid archive format %q, expected %v", format, strings.Join(archiveFormats(), " or "))
+}
+
+// copyInto copies the file at rel, relative to dir, into the archive entry
+// that create starts for it.
+func copyInto(dir, rel string, create func(os.FileInfo) (io.Writer, error)) error {
+	file, err := os.Open(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return fmt.Errorf("error reading %v: %w", rel, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("error reading %v: %w", rel, err)
+	}
+	w, err := create(info)
+	if err != nil {
+		return fmt.Errorf("error archiving %v: %w", rel, err)
+	}
+	if _, err := io.Copy(w, file); err != nil {
+		return fmt.Errorf("error archiving %v: %w", rel, err)
+	}
+	return nil
+}
+
+// archiveFormats returns the -archive formats in order.
+func archiveFormats() []string {
+	var formats []string
+	for format := range archiveExtensions {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
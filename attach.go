@@ -0,0 +1,1369 @@
+package jiraattach
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const attachUsage = `usage: jiraattach attach [-allow-secrets] [-sign=key.pem]
+  [-junit=report.xml] [-junit-failures=n] [-transcode] [-wait=duration]
+  [-wait-scan=duration] [-no-progress] [-status-file=path]
+  [-name|-filename=filename] [-tee] [-recent] [-preview]
+  [-comment-on-failure=template] [-continue-on-error|-fail-fast]
+  [-name-template=template] [-enforce-budget] [-no-comment] [-replace]
+  [-skip-existing] [-m|-message=template]
+  [-visible-to-role=role|-visible-to-group=group] [-internal] [-jql=query
+  [-dry-run] [-yes]] [-check] [-output=text|json] [-archive=zip|tar.gz]
+  [-include=pattern]... [-exclude=pattern]... [-r|-recursive]
+  [-compress[=gzip|zstd]] [-split] [-as=filename] [-content-type=type]
+  [-no-embed] [-exec=command] [-concurrency=n] [-resume] key path...
+
+  Attach files to a Jira Issue. This is the default command, so the command
+  name may be omitted. When several files are attached a single comment
+  linking to all of them is posted. Flags may follow the key and path.
+
+INPUT
+
+  path - A file, or a glob pattern such as 'logs/*.gz', which is expanded in
+  sorted order even where the shell doesn't expand it. Files are attached
+  under their base name, without the directories in their path. A path may
+  also be an http or https URL, such as an expiring CI artifact link, which
+  is fetched and uploaded as it downloads, named after its
+  Content-Disposition header or its path, or an s3://bucket/key or
+  gs://bucket/key object, streamed with the aws or gcloud tool and its usual
+  credentials; allowed_sources limits where from.
+
+  -name, -filename - The name to attach stdin as, given as a path of -.
+
+  -tee - Copy stdin to stdout too, so the command can sit in the middle of a
+  pipeline.
+
+  -exec - Leave out the paths and attach the shell command's stdout to the
+  single issue as it is written, named by -filename or after the command. A
+  comment gives its exit status, and jiraattach exits with 9 when the
+  command fails.
+
+  -junit - Attach the JUnit XML report and post a comment summarizing the
+  test totals and the first -junit-failures failing tests; path is then
+  optional.
+
+  -archive - A directory is attached as a single archive named after it,
+  built while it is uploaded, zip unless -archive=tar.gz is given. Files
+  listed in a .jiraattachignore file, one glob pattern per line, are left
+  out of the directory it is in, archived or not.
+
+  -include, -exclude - Select the files in a directory by glob patterns such
+  as '*.xml', matched against each file's name and its path within the
+  directory; an excluded directory is left out with everything in it. Both
+  may be repeated.
+
+  -r, -recursive - Attach every file under a directory separately instead of
+  as an archive, selected the same way.
+
+ISSUES
+
+  key - Several issues may be given as comma separated keys, such as
+  PROJ-1,PROJ-2, or as further keys before the paths. The files are attached
+  to each in turn, the outcome for each issue is reported, and an issue that
+  fails doesn't stop the others unless -fail-fast is given.
+
+  -recent - Leave out the key and choose the issue from a searchable list of
+  the issues uploaded to recently.
+
+  -jql - Leave out the keys and attach the files to every issue the JQL
+  query finds, once the issues have been listed and the upload confirmed.
+  -dry-run only lists them and -yes skips the confirmation.
+
+CHECKS
+
+  -allow-secrets - Attach text files that appear to contain secrets such as
+  AWS keys, private keys or JWTs, which are otherwise refused.
+
+  -check - Fetch the issue before anything is uploaded, failing with a plain
+  error if it doesn't exist, is closed or archived, or the account lacks the
+  Create Attachments permission on it.
+
+  -preview - Show the summary, status, assignee and reporter of the issue,
+  and only go ahead once confirmed.
+
+  -enforce-budget - Attach nothing when the files would take the issue over
+  issue_budget, instead of only warning.
+
+  -skip-existing - Skip files already attached with the same name, size and
+  SHA-256, so re-running a job doesn't attach them again. When an attachment
+  can't be downloaded to compare, a warning is given and the file is
+  attached.
+
+  A file larger than the instance's attachment size limit is refused before
+  it is uploaded, exiting with status 5, unless -split is given.
+
+TRANSFORMS
+
+  -compress - Compress each file before upload and append .gz, or .zst for
+  -compress=zstd, to its name. Text files larger than
+  auto_compress_threshold are compressed with gzip regardless.
+
+  -split - Attach a file larger than the size limit as parts no larger than
+  the limit, named like app.log.part01, and explain in the comment how to
+  join them.
+
+  -transcode - Re-encode videos as 720p H.264 MP4 with ffmpeg before they
+  are attached.
+
+  -as - Attach the single file given under a different name.
+
+  -name-template - Name the attached files from a text/template such as
+  "{{date}}-{{hostname}}-{{basename}}", which may use date, time, hostname,
+  user, issue, basename, stem and ext.
+
+  -content-type - Upload with this MIME type. Otherwise it is found from
+  each file's extension or, failing that, its content, so Jira previews
+  images and PDFs inline.
+
+UPLOAD
+
+  -concurrency - Upload up to n files at once, across every issue given.
+  Results and comments still list the files in the order given.
+
+  -continue-on-error - When more than one file is attached the outcome of
+  each is reported, and by default the first failure stops the run. With
+  -continue-on-error the remaining files are still attached and the command
+  fails at the end if any file failed.
+
+  -no-progress - Don't draw the progress bar showing the bytes sent, the
+  percentage and the estimated time remaining, which is otherwise drawn on
+  stderr when it is a terminal. With -concurrency it shows every upload
+  combined.
+
+  -status-file - Write the progress and estimated time remaining of the
+  current uploads to the file every second. Sending the process SIGUSR1
+  prints them to stderr.
+
+  -resume - A file attached with -split whose upload stops with some parts
+  attached, from a dropped connection or Ctrl-C, is saved in the state
+  directory and the next attach of the same file to the same issue says so;
+  -resume continues from the first part not yet attached. A whole file is
+  simply sent again, since Jira can't continue a partly sent one.
+
+AFTER UPLOAD
+
+  -no-comment - Don't post the comment linking to the files.
+
+  -no-embed - Link images in the comment rather than showing them as
+  thumbnails. Without it the comment is posted even for a single image.
+
+  -m, -message - Render the comment from this text/template instead, such as
+  "Nightly build logs: {{.Filename}} {{.URL}}", where Filename and URL are
+  those of the first file and {{range .Files}} lists them all. It is posted
+  even for a single file.
+
+  -visible-to-role, -visible-to-group - Only show the comments posted to
+  members of that project role or group, keeping them from customers and
+  other external viewers.
+
+  -internal - Post the comments as internal notes on Jira Service Management
+  issues, hidden from the customer portal.
+
+  -comment-on-failure - Post a comment rendered from this text/template,
+  such as "Upload of {{.Filename}} failed: {{.Error}}", on the issue when an
+  upload fails.
+
+  -replace - Delete the attachments already on the issue with the same
+  filename as a new file, once the new file is attached.
+
+  -sign - Attach a manifest of the attached files' names, sizes and SHA-256
+  hashes too, along with a detached signature made with the RSA, ECDSA or
+  Ed25519 key.
+
+  -wait - Poll the issue after uploading, for up to this long such as 30s,
+  until the attachments are listed on it, failing if they don't appear in
+  time.
+
+  -wait-scan - Poll each attachment until Data Center attachment scanning
+  has cleared it, failing if it is quarantined.
+
+OUTPUT
+
+  The content URL of each attachment is printed on stdout, one per line,
+  while everything else goes to stderr, so URL=$(jiraattach KEY file) works.
+  With -tee nothing but stdin is written to stdout.
+
+  -output - With json, print a JSON object on stdout for each issue instead,
+  giving the id, filename, content URL, thumbnail URL and size of each
+  attachment and the id of the comment posted.
+`
+
+// runAttach implements the attach command, uploading a file to an issue.
+func runAttach(config *Config, args []string) error {
+	fs := newFlagSet("attach", attachUsage)
+	allowsecrets := fs.Bool("allow-secrets", config.AllowSecrets, "attach files even if they appear to contain secrets")
+	signkey := fs.String("sign", "", "path to a PEM private key used to sign a manifest of the attached files")
+	junit := fs.String("junit", "", "path to a JUnit XML report to attach and summarize in a comment")
+	junitfailures := fs.Int("junit-failures", 10, "number of failing tests to list in the JUnit summary")
+	fs.StringVar(&config.contentType, "content-type", "", "MIME type to upload files as instead of detecting it from their name and content, such as text/plain")
+	split := fs.Bool("split", config.Split, "split files larger than Jira accepts into numbered parts under the limit")
+	fs.BoolVar(&config.resume, "resume", false, "continue files whose upload was interrupted, skipping the parts of split files already attached")
+	compress := config.Compress
+	fs.Var(compressFlag{&compress}, "compress", "compress files with gzip before attaching, appending .gz to their names; -compress=zstd uses zstd and .zst instead")
+	transcode := fs.Bool("transcode", config.Transcode.Enabled, "re-encode videos as H.264 with ffmpeg before attaching")
+	concurrency := fs.Int("concurrency", config.concurrency(), "number of files to upload at once, across every issue being attached to")
+	noprogress := fs.Bool("no-progress", false, "don't draw a progress bar on stderr while uploading")
+	statusfile := fs.String("status-file", "", "path to a file rewritten every second with the progress of the current upload")
+	wait := fs.Duration("wait", 0, "after uploading, wait up to this long for the attachments to appear on the issue")
+	waitscan := fs.Duration("wait-scan", 0, "after uploading, wait up to this long for Data Center attachment scanning to clear the attachments")
+	name := fs.String("name", "", "filename to attach stdin as when path is -")
+	execcmd := fs.String("exec", "", "run this shell command and attach its output, streamed as it is written, named by -filename")
+	as := fs.String("as", "", "filename shown in Jira for the single file attached, instead of its local name")
+	fs.StringVar(name, "filename", "", "same as -name")
+	tee := fs.Bool("tee", false, "copy stdin to stdout while attaching it")
+	failurecomment := fs.String("comment-on-failure", "", "text/template for a comment posted on the issue when an upload fails, with {{.Filename}} and {{.Error}}")
+	nametemplate := fs.String("name-template", "", "template for the attached filenames, such as {{date}}-{{hostname}}-{{basename}}")
+	continueonerror := fs.Bool("continue-on-error", false, "keep attaching the remaining files after one fails")
+	failfast := fs.Bool("fail-fast", false, "stop at the first file that fails to attach, the default")
+	enforcebudget := fs.Bool("enforce-budget", false, "refuse to attach files that would take the issue over issue_budget")
+	skipexisting := fs.Bool("skip-existing", false, "don't attach files already attached with the same name, size and content")
+	replace := fs.Bool("replace", false, "delete existing attachments with the same filename once the new file is attached")
+	output := fs.String("output", "text", "output format, text or json for a JSON object per issue on stdout describing the attachments and comment")
+	nocomment := fs.Bool("no-comment", false, "don't post a comment listing the files when attaching several")
+	noembed := fs.Bool("no-embed", false, "link to attached images in the comment instead of showing thumbnails of them")
+	message := fs.String("message", "", "text/template for the comment posted once the files are attached, with {{.Filename}} and {{.URL}}")
+	fs.StringVar(message, "m", "", "same as -message")
+	commentflags := addCommentFlags(fs)
+	check := fs.Bool("check", false, "before uploading, check that the issue exists, isn't closed or archived and accepts attachments from you")
+	preview := fs.Bool("preview", false, "show the issue and ask for confirmation before attaching")
+	recent := fs.Bool("recent", false, "choose the issue from those recently uploaded to instead of giving its key")
+	jql := fs.String("jql", "", "attach to every issue found by this JQL query instead of giving keys, after listing them and asking for confirmation")
+	dryrun := fs.Bool("dry-run", false, "with -jql, list the issues found without attaching anything")
+	yes := fs.Bool("yes", false, "with -jql, attach without asking for confirmation")
+	archive := fs.String("archive", "zip", "format directories are attached in, zip or tar.gz")
+	recursive := fs.Bool("recursive", false, "attach every file under directories separately instead of as an archive")
+	fs.BoolVar(recursive, "r", false, "same as -recursive")
+	var filter fileFilter
+	fs.Var((*StringList)(&filter.include), "include", "when attaching a directory, only attach files matching this glob pattern; may be repeated")
+	fs.Var((*StringList)(&filter.exclude), "exclude", "when attaching a directory, leave out files and directories matching this glob pattern; may be repeated")
+	if err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	config.AllowSecrets = *allowsecrets
+	config.Transcode.Enabled = *transcode
+	config.Split = *split
+	config.Compress = compress
+
+	args = fs.Args()
+	if *recent && *jql != "" {
+		return usageErrorf("-recent and -jql can't be used together")
+	}
+	if (*dryrun || *yes) && *jql == "" {
+		return usageErrorf("-dry-run and -yes can only be used with -jql")
+	}
+	var keys []string
+	if !*recent && *jql == "" {
+		if len(args) < 1 {
+			return usageErrorf("key and path are required")
+		}
+		if keys, args = splitKeys(args); len(keys) == 0 {
+			return usageErrorf("key and path are required")
+		}
+		for i := range keys {
+			key, err := config.issueKey(keys[i])
+			if err != nil {
+				return err
+			}
+			keys[i] = key
+		}
+	}
+	if len(args) < 1 && *junit == "" && *execcmd == "" {
+		return usageErrorf("key and path are required")
+	}
+	paths, err := expandGlobs(args)
+	if err != nil {
+		return err
+	}
+	if err := filter.validate(); err != nil {
+		return err
+	}
+	if *recursive {
+		if paths, err = expandDirs(paths, filter); err != nil {
+			return err
+		}
+	}
+	commentopts, err := commentflags.options()
+	if err != nil {
+		return err
+	}
+	if *execcmd != "" {
+		if len(paths) > 0 || *junit != "" || len(keys) != 1 {
+			return usageErrorf("-exec attaches to a single issue and can't be used with paths")
+		}
+		if *as != "" {
+			*name = *as
+		}
+		return attachExec(config.route(keys[0]), keys[0], *execcmd, *name, commentopts)
+	}
+	if *message != "" && *nocomment {
+		return usageErrorf("-message and -no-comment can't be used together")
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid -output %q, expected text or json", *output)
+	}
+	if *concurrency < 1 {
+		return usageErrorf("-concurrency must be at least 1")
+	}
+	if *continueonerror && *failfast {
+		return usageErrorf("-continue-on-error and -fail-fast can't be used together")
+	}
+	if _, ok := archiveExtensions[*archive]; !ok {
+		return usageErrorf("invalid -archive %q, expected %v", *archive, strings.Join(archiveFormats(), " or "))
+	}
+	if _, ok := compressExtensions[config.Compress]; config.Compress != "" && !ok {
+		return usageErrorf("invalid -compress %q, expected %v", config.Compress, strings.Join(compressFormats(), " or "))
+	}
+	stdin := false
+	for _, path := range paths {
+		if path == "-" {
+			if stdin {
+				return usageErrorf("stdin can only be attached once")
+			}
+			stdin = true
+		}
+	}
+	if config.contentType != "" {
+		if _, _, err := mime.ParseMediaType(config.contentType); err != nil {
+			return usageErrorf("invalid -content-type %q: %v", config.contentType, err)
+		}
+	}
+	if *as != "" {
+		if len(paths) != 1 {
+			return usageErrorf("-as can only be used when attaching a single file")
+		}
+		if err := checkName(*as); err != nil {
+			return usageErrorf("invalid -as: %v", err)
+		}
+		if stdin && *name == "" {
+			*name = *as
+		}
+	}
+	if stdin && *name == "" {
+		return usageErrorf("-name or -filename is required when reading from stdin")
+	}
+	if stdin && (*recent || *preview || *jql != "") {
+		return usageErrorf("-recent, -preview and -jql can't be used when reading from stdin")
+	}
+	if *tee && !stdin {
+		return usageErrorf("-tee can only be used when reading from stdin")
+	}
+	if *tee && *output == "json" {
+		return usageErrorf("-tee and -output json can't be used together, both write to stdout")
+	}
+	if stdin && len(keys) > 1 {
+		return usageErrorf("stdin can only be attached to one issue")
+	}
+	if *junit != "" {
+		paths = append(paths, *junit)
+	}
+	if *recent {
+		key, err := pickRecentIssue()
+		if err != nil {
+			return err
+		}
+		keys = []string{key}
+	}
+	if *jql != "" {
+		issues, err := config.client().search(*jql, "summary")
+		if err != nil {
+			return fmt.Errorf("error searching for issues: %w", err)
+		}
+		if len(issues) == 0 {
+			return fmt.Errorf("no issues match %v", *jql)
+		}
+		// Only a dry run's listing is the output; otherwise stdout is left
+		// for -output.
+		list := os.Stderr
+		if *dryrun {
+			list = os.Stdout
+		}
+		for _, issue := range issues {
+			fmt.Fprintf(list, "%v\t%v\n", issue.Key, issue.Fields.Summary)
+			keys = append(keys, issue.Key)
+		}
+		if *dryrun {
+			return nil
+		}
+		if !*yes && !confirm(fmt.Sprintf("Attach %d files to these %d issues?", len(paths), len(issues)), false) {
+			return nil
+		}
+	}
+
+	var nametmpl *template.Template
+	if *nametemplate != "" {
+		var err error
+		if nametmpl, err = parseNameTemplate(*nametemplate); err != nil {
+			return err
+		}
+	}
+	var onfailure *template.Template
+	if *failurecomment != "" {
+		var err error
+		if onfailure, err = template.New("failure").Parse(*failurecomment); err != nil {
+			return fmt.Errorf("error parsing -comment-on-failure template: %w", err)
+		}
+	}
+	var msgtmpl *template.Template
+	switch {
+	case *message != "":
+		var err error
+		if msgtmpl, err = parseMessage("-message template", *message); err != nil {
+			return err
+		}
+	case config.CommentTemplate != "" && !*nocomment:
+		var err error
+		if msgtmpl, err = parseMessage("comment_template", config.CommentTemplate); err != nil {
+			return err
+		}
+	}
+
+	var signer crypto.Signer
+	if *signkey != "" {
+		var err error
+		if signer, err = loadSigner(*signkey); err != nil {
+			return err
+		}
+	}
+	var report *junitTotals
+	if *junit != "" {
+		var err error
+		if report, err = readJUnit(*junit); err != nil {
+			return err
+		}
+	}
+
+	// slots limits the number of files uploaded at once, across every issue.
+	slots := make(chan struct{}, *concurrency)
+
+	// attachTo attaches the files to the issue identified by key.
+	attachTo := func(key string) error {
+		config := config.route(key)
+		if *check {
+			if err := checkIssue(config.client(), key); err != nil {
+				return err
+			}
+		}
+		if *preview {
+			if err := previewIssue(config.client(), key); err != nil {
+				return err
+			}
+		}
+
+		if config.issueBudget() > 0 || *enforcebudget {
+			var adding int64
+			for _, path := range paths {
+				if path == "-" {
+					continue
+				}
+				if info, err := os.Stat(path); err == nil && !info.IsDir() {
+					adding += info.Size()
+				}
+			}
+			if err := checkBudget(config, key, adding, *enforcebudget); err != nil {
+				return err
+			}
+		}
+
+		var existing []Attachment
+		if *replace || *skipexisting {
+			var err error
+			if existing, err = config.client().attachments(key); err != nil {
+				return fmt.Errorf("error listing attachments on %v: %w", key, err)
+			}
+		}
+
+		// attach uploads one file as filename, returning its SHA-256 when a
+		// manifest is being signed.
+		attach := func(path, filename string) ([]Attachment, string, error) {
+			var source *remoteSource
+			if isRemoteSource(path) {
+				var err error
+				if source, err = config.openSource(path); err != nil {
+					return nil, "", err
+				}
+				defer source.Close()
+				if filename == "" {
+					filename = source.name
+				}
+			}
+			if nametmpl != nil {
+				var err error
+				if filename, err = renderName(nametmpl, key, filename); err != nil {
+					return nil, "", err
+				}
+			}
+			if *skipexisting && path != "-" && source == nil && alreadyAttached(config.client(), existing, path, filename) {
+				return nil, "", errAlreadyAttached
+			}
+			var (
+				attachments []Attachment
+				sum         string
+				err         error
+			)
+			if source != nil {
+				attachments, sum, err = attachSource(config, key, filename, source)
+			} else if path == "-" {
+				attachments, sum, err = attachStdin(config, key, filename, *tee)
+			} else if isDir(path) {
+				attachments, sum, err = attachDir(config, key, path, filename, *archive, filter)
+			} else {
+				attachments, err = attachPath(config, key, path, filename)
+				if err == nil && signer != nil {
+					if sum, err = hashFile(path); err != nil {
+						err = fmt.Errorf("error reading attachment, %v: %w", path, err)
+					}
+				}
+			}
+			if err != nil {
+				return nil, "", err
+			}
+			if *replace {
+				if err := replaceAttachments(config.client(), key, existing, attachments); err != nil {
+					return attachments, sum, err
+				}
+			}
+			return attachments, sum, nil
+		}
+
+		var (
+			files    []manifestFile
+			summary  string
+			uploaded []Attachment
+			listed   []Attachment
+			parts    [][]Attachment
+			results  = make([]fileResult, len(paths))
+			attached = make([][]Attachment, len(paths))
+			sums     = make([]string, len(paths))
+			failed   error
+			mu       sync.Mutex
+			wg       sync.WaitGroup
+		)
+		for i, path := range paths {
+			results[i].name = path
+			filename := sanitizeName(filepath.Base(path))
+			switch {
+			case path == "-":
+				results[i].name, filename = *name, *name
+			case isRemoteSource(path):
+				// Named once fetched, after its Content-Disposition.
+				filename = ""
+			case isDir(path):
+				results[i].name = archiveName(path, *archive)
+				filename = results[i].name
+			}
+			if *as != "" {
+				filename = *as
+			}
+			slots <- struct{}{}
+			mu.Lock()
+			stop := failed != nil && !*continueonerror
+			mu.Unlock()
+			if stop || config.settings.context().Err() != nil {
+				<-slots
+				results[i].skipped = true
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, path, filename string) {
+				defer wg.Done()
+				// The slot is freed only once the result is recorded, so
+				// the next file isn't started after a failure.
+				defer func() { <-slots }()
+				attachments, sum, err := attach(path, filename)
+				mu.Lock()
+				defer mu.Unlock()
+				if err == errAlreadyAttached {
+					results[i].existing = true
+					return
+				}
+				if err != nil {
+					results[i].err = err
+					if failed == nil {
+						failed = err
+					}
+					if onfailure != nil {
+						failedName := filename
+						if failedName == "" {
+							failedName = path
+						}
+						commentFailure(config.client(), key, onfailure, commentopts, failedName, err)
+					}
+					return
+				}
+				attached[i], sums[i] = attachments, sum
+			}(i, path, filename)
+		}
+		wg.Wait()
+
+		// Files are listed in the order they were given, whichever finished
+		// uploading first.
+		for i, path := range paths {
+			attachments := attached[i]
+			if len(attachments) > 1 {
+				parts = append(parts, attachments)
+			}
+			uploaded = append(uploaded, attachments...)
+			if report != nil && path == *junit && len(attachments) > 0 {
+				summary = report.comment(attachments[0].Filename, *junitfailures)
+			} else {
+				listed = append(listed, attachments...)
+			}
+			for _, a := range attachments {
+				files = append(files, manifestFile{Filename: a.Filename, Size: a.Size, SHA256: sums[i]})
+			}
+		}
+		if len(paths) > 1 || *skipexisting {
+			withoutProgress(func() {
+				printResults(results)
+			})
+		}
+		if failed != nil && !*continueonerror {
+			return failed
+		}
+
+		var comment []string
+		switch {
+		case msgtmpl != nil && len(listed) > 0:
+			text, err := renderMessage(msgtmpl, key, listed)
+			if err != nil {
+				return err
+			}
+			comment = append(comment, text)
+		case len(listed) > 1 && !*nocomment:
+			comment = append(comment, attachedComment(listed, !*noembed))
+		case len(listed) == 1 && !*nocomment && !*noembed && isImage(listed[0]):
+			comment = append(comment, embedImages(listed))
+		}
+		if len(parts) > 0 && !*nocomment {
+			comment = append(comment, splitComment(parts))
+		}
+		if summary != "" {
+			comment = append(comment, summary)
+		}
+		var posted *Comment
+		if len(comment) > 0 {
+			var err error
+			if posted, err = config.client().comment(key, strings.Join(comment, "\n\n"), commentopts); err != nil {
+				return fmt.Errorf("error commenting on %v: %w", key, err)
+			}
+		}
+		if signer != nil {
+			if err := attachManifest(config, key, signer, files); err != nil {
+				return err
+			}
+		}
+		if *wait > 0 {
+			if err := waitForAttachments(config.client(), key, uploaded, *wait); err != nil {
+				return err
+			}
+		}
+		if *waitscan > 0 {
+			if err := waitForScan(config.client(), uploaded, *waitscan); err != nil {
+				return err
+			}
+		}
+		var err error
+		withoutProgress(func() {
+			switch {
+			case *output == "json":
+				err = printAttachOutput(key, uploaded, posted)
+			case !*tee:
+				// Only the URLs go to stdout, so scripts can capture them.
+				for _, a := range uploaded {
+					fmt.Println(a.Content)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		if failed != nil {
+			n := 0
+			for _, r := range results {
+				if r.err != nil {
+					n++
+				}
+			}
+			return partialError(fmt.Errorf("%d of %d files could not be attached", n, len(paths)), n, len(paths), failed)
+		}
+		return nil
+	}
+
+	stopProgress := func() {}
+	if !*noprogress {
+		stopProgress = showProgress()
+		defer stopProgress()
+	}
+	if *statusfile != "" {
+		defer writeProgress(*statusfile)()
+	}
+	if *concurrency > 1 {
+		config.routeAll(keys)
+	}
+	if len(keys) == 1 {
+		return attachTo(keys[0])
+	}
+
+	// Issues are attached to in parallel too, except with -preview, which
+	// asks about each in turn.
+	issues := make(chan struct{}, *concurrency)
+	if *preview {
+		issues = make(chan struct{}, 1)
+	}
+	var (
+		results = make([]fileResult, len(keys))
+		failed  int
+		first   error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	for i, key := range keys {
+		results[i].name = key
+		issues <- struct{}{}
+		mu.Lock()
+		stop := failed > 0 && *failfast
+		mu.Unlock()
+		if stop || config.settings.context().Err() != nil {
+			<-issues
+			results[i].skipped = true
+			continue
+		}
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			defer func() { <-issues }()
+			err := attachTo(key)
+			if err != nil {
+				mu.Lock()
+				results[i].err = err
+				if failed == 0 {
+					first = err
+				}
+				failed++
+				mu.Unlock()
+			}
+		}(i, key)
+	}
+	wg.Wait()
+	stopProgress()
+	printResults(results)
+	if failed > 0 {
+		return partialError(fmt.Errorf("%d of %d issues failed", failed, len(keys)), failed, len(keys), first)
+	}
+	return nil
+}
+
+// attachOutput describes what attach did to an issue for -output json.
+type attachOutput struct {
+	Issue       string             `json:"issue"`
+	Attachments []attachmentOutput `json:"attachments"`
+	CommentID   string             `json:"comment_id,omitempty"`
+}
+
+type attachmentOutput struct {
+	ID           string `json:"id"`
+	Filename     string `json:"filename"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	Size         int64  `json:"size"`
+}
+
+// printAttachOutput writes the attachments added to key, and the comment
+// posted about them, as a line of JSON on stdout.
+func printAttachOutput(key string, attachments []Attachment, comment *Comment) error {
+	out := attachOutput{Issue: key, Attachments: []attachmentOutput{}}
+	for _, a := range attachments {
+		out.Attachments = append(out.Attachments, attachmentOutput{
+			ID:           a.ID,
+			Filename:     a.Filename,
+			URL:          a.Content,
+			ThumbnailURL: a.Thumbnail,
+			Size:         a.Size,
+		})
+	}
+	if comment != nil {
+		out.CommentID = comment.ID
+	}
+	if err := json.NewEncoder(os.Stdout).Encode(out); err != nil {
+		return fmt.Errorf("error writing output: %w", err)
+	}
+	return nil
+}
+
+// splitKeys takes the issue keys from the start of args: the first argument,
+// which may list several keys separated by commas, and any arguments after
+// it that look like issue keys or issue URLs and aren't files. The keys may
+// still be URLs, see issueKey.
+func splitKeys(args []string) ([]string, []string) {
+	var keys []string
+	for _, key := range strings.Split(args[0], ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	args = args[1:]
+	for len(args) > 0 && isIssueArg(args[0]) {
+		if _, err := os.Stat(args[0]); err == nil {
+			break
+		}
+		keys, args = append(keys, args[0]), args[1:]
+	}
+	return keys, args
+}
+
+// isIssueArg reports whether arg is an issue key or an issue's URL.
+func isIssueArg(arg string) bool {
+	_, ok := parseIssueURL(arg)
+	return ok || issueKeyPattern.MatchString(arg)
+}
+
+// attachedComment lists the attachments in wiki markup, linking to each, so
+// that attaching several files posts one comment rather than one per file.
+// With embed the images among them are shown as thumbnails below the list.
+func attachedComment(attachments []Attachment, embed bool) string {
+	lines := []string{fmt.Sprintf("Attached %d files:", len(attachments))}
+	for _, a := range attachments {
+		lines = append(lines, "* [^"+a.Filename+"]")
+	}
+	comment := strings.Join(lines, "\n")
+	if images := embedImages(attachments); embed && images != "" {
+		comment += "\n\n" + images
+	}
+	return comment
+}
+
+// embedImages embeds the images among the attachments as thumbnails in wiki
+// markup, one per line, returning "" when there are none.
+func embedImages(attachments []Attachment) string {
+	var lines []string
+	for _, a := range attachments {
+		if isImage(a) {
+			lines = append(lines, "!"+a.Filename+"|thumbnail!")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isImage reports whether the attachment is an image Jira can show a
+// thumbnail of.
+func isImage(a Attachment) bool {
+	return strings.HasPrefix(a.MimeType, "image/") || galleryImages[strings.ToLower(filepath.Ext(a.Filename))]
+}
+
+// messageData is passed to the comment template given by -message or
+// comment_template. Filename, URL and Size are those of the first file
+// attached, and Files lists every one of them.
+type messageData struct {
+	Issue     string
+	Filename  string
+	URL       string
+	Size      int64
+	Files     []messageFile
+	Hostname  string
+	Timestamp time.Time
+	Env       map[string]string
+}
+
+// messageFile is an attached file listed in messageData.
+type messageFile struct {
+	Filename string
+	URL      string
+	Size     int64
+}
+
+// parseMessage parses a comment template, named by where it came from for
+// errors.
+func parseMessage(source, text string) (*template.Template, error) {
+	// Unset environment variables render as empty rather than "<no value>".
+	t, err := template.New("message").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %v: %w", source, err)
+	}
+	return t, nil
+}
+
+// renderMessage renders the comment template for attachments added to key.
+func renderMessage(t *template.Template, key string, attachments []Attachment) (string, error) {
+	data := messageData{Issue: key, Timestamp: time.Now(), Env: map[string]string{}}
+	data.Hostname, _ = os.Hostname()
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			data.Env[kv[:i]] = kv[i+1:]
+		}
+	}
+	for _, a := range attachments {
+		data.Files = append(data.Files, messageFile{Filename: a.Filename, URL: a.Content, Size: a.Size})
+	}
+	first := data.Files[0]
+	data.Filename, data.URL, data.Size = first.Filename, first.URL, first.Size
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("error rendering comment template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// fileResult is the outcome of attaching one file of a multi-file run.
+type fileResult struct {
+	name     string
+	err      error
+	skipped  bool
+	existing bool
+}
+
+// printResults reports the outcome of each file of a multi-file run on
+// stderr.
+func printResults(results []fileResult) {
+	for _, r := range results {
+		switch {
+		case r.skipped:
+			fmt.Fprintf(os.Stderr, "skipped   %v\n", redact(r.name))
+		case r.existing:
+			fmt.Fprintf(os.Stderr, "exists    %v\n", redact(r.name))
+		case r.err != nil:
+			fmt.Fprintf(os.Stderr, "failed    %v: %v\n", redact(r.name), redact(r.err.Error()))
+		default:
+			fmt.Fprintf(os.Stderr, "attached  %v\n", redact(r.name))
+		}
+	}
+}
+
+// failureData is passed to the -comment-on-failure template.
+type failureData struct {
+	Issue    string
+	Filename string
+	Error    string
+}
+
+// commentFailure posts a comment from t noting that filename could not be
+// attached, so that the failure is visible on the issue rather than only in
+// a CI log. A comment that can't be posted is reported as a warning since
+// the upload error is what matters.
+func commentFailure(c *client, key string, t *template.Template, opts commentOptions, filename string, uploadErr error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, failureData{Issue: key, Filename: filename, Error: redact(uploadErr.Error())}); err != nil {
+		warnf("unable to render failure comment: %v", err)
+		return
+	}
+	if _, err := c.comment(key, buf.String(), opts); err != nil {
+		warnf("unable to comment on %v: %v", key, err)
+	}
+}
+
+// previewIssue shows who and what the issue is about, then asks whether to
+// attach to it, guarding against attaching to a mistyped key.
+func previewIssue(c *client, key string) error {
+	issue, err := c.issue(key, "summary", "status", "assignee", "reporter")
+	if err != nil {
+		return fmt.Errorf("error fetching %v: %w", key, err)
+	}
+	name := func(u *User) string {
+		if u == nil {
+			return "Unassigned"
+		}
+		return u.DisplayName
+	}
+	status := ""
+	if issue.Fields.Status != nil {
+		status = issue.Fields.Status.Name
+	}
+	fmt.Fprintf(os.Stderr, "%v  %v\n", issue.Key, issue.Fields.Summary)
+	fmt.Fprintf(os.Stderr, "  Status:    %v\n", status)
+	fmt.Fprintf(os.Stderr, "  Assignee:  %v\n", name(issue.Fields.Assignee))
+	fmt.Fprintf(os.Stderr, "  Reporter:  %v\n", name(issue.Fields.Reporter))
+	if !confirm("Attach to this issue?", true) {
+		return fmt.Errorf("not attached")
+	}
+	return nil
+}
+
+// checkIssue checks that the issue exists, isn't closed or archived and
+// that the user may attach files to it, so a mistake is reported plainly
+// before a long upload rather than as an error response after it.
+func checkIssue(c *client, key string) error {
+	req, err := c.newRequest("GET", "/rest/api/2/issue/"+url.PathEscape(key)+"?fields=status,archiveddate", nil)
+	if err != nil {
+		return err
+	}
+	var issue struct {
+		Fields struct {
+			Status       *Status `json:"status"`
+			ArchivedDate string  `json:"archiveddate"`
+		} `json:"fields"`
+	}
+	if err := c.do(req, &issue); err != nil {
+		if e, ok := err.(*statusError); ok && e.StatusCode == http.StatusNotFound {
+			return &exitError{code: exitNotFound, err: fmt.Errorf("issue %v not found, or you don't have permission to see it", key)}
+		}
+		return fmt.Errorf("error fetching %v: %w", key, err)
+	}
+	if issue.Fields.ArchivedDate != "" {
+		return fmt.Errorf("issue %v is archived", key)
+	}
+	if s := issue.Fields.Status; s != nil && s.Category != nil && s.Category.Key == "done" {
+		return fmt.Errorf("issue %v is closed, its status is %v", key, s.Name)
+	}
+	if err := c.canAttachTo(key); err != nil {
+		return &exitError{code: exitCode(err), err: fmt.Errorf("can't attach files to %v: %w", key, err)}
+	}
+	return nil
+}
+
+// waitForAttachments polls the issue until every attachment is listed on it,
+// so that automation reading the issue next sees them even when the
+// instance is slow to make new attachments visible.
+func waitForAttachments(c *client, key string, attachments []Attachment, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	interval := 250 * time.Millisecond
+	for {
+		current, err := c.attachments(key)
+		if err != nil {
+			return fmt.Errorf("error listing attachments on %v: %w", key, err)
+		}
+		visible := map[string]bool{}
+		for _, a := range current {
+			visible[a.ID] = true
+		}
+		var missing []string
+		for _, a := range attachments {
+			if !visible[a.ID] {
+				missing = append(missing, a.Filename)
+			}
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("timed out waiting for %v to appear on %v", strings.Join(missing, ", "), key)
+		}
+		time.Sleep(interval)
+		if interval < 4*time.Second {
+			interval *= 2
+		}
+	}
+}
+
+// attachPath uploads the file at path to the issue as name, transcoding it
+// first if it is a video and transcoding is enabled. A split upload that
+// fails with some parts attached is saved so that it can be resumed.
+func attachPath(config *Config, key, path, name string) ([]Attachment, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading attachment, %v: %w", path, err)
+	}
+	config = config.startUpload(key, path, info)
+
+	path, name, cleanup, err := config.Transcode.transcode(path, name)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading attachment, %v: %w", path, err)
+	}
+	defer file.Close()
+	attachments, err := attachFile(config, key, name, file)
+	if err != nil {
+		if msg, ok := config.pending.interrupted(name); ok {
+			warnf("%v", msg)
+		}
+		return nil, err
+	}
+	if err := config.pending.finish(); err != nil {
+		warnf("unable to record finished upload: %v", err)
+	}
+	return attachments, nil
+}
+
+// errAlreadyAttached is returned for files skipped by -skip-existing.
+var errAlreadyAttached = errors.New("already attached")
+
+// alreadyAttached reports whether the file at path is already attached to
+// the issue as filename: an attachment with the same name, size and SHA-256
+// exists. Files that can't be read are reported as not attached, leaving the
+// upload to report why, and so are attachments that can't be downloaded to
+// compare, with a warning, since a name and size alone don't mean the
+// content is the same.
+func alreadyAttached(c *client, existing []Attachment, path, filename string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	var sum string
+	for _, a := range existing {
+		if a.Filename != filename || a.Size != info.Size() {
+			continue
+		}
+		if sum == "" {
+			if sum, err = hashFile(path); err != nil {
+				return false
+			}
+		}
+		remote, err := hashAttachment(c, a)
+		if err != nil {
+			warnf("couldn't compare %v with attachment %v, attaching it anyway: %v", path, a.ID, err)
+			continue
+		}
+		if remote == sum {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceAttachments deletes the attachments in existing that share a
+// filename with one just uploaded. The old copies are only deleted once the
+// new file is safely attached, so a failed upload never loses them.
+func replaceAttachments(c *client, key string, existing, uploaded []Attachment) error {
+	for _, u := range uploaded {
+		for _, a := range existing {
+			if a.Filename != u.Filename || a.ID == u.ID {
+				continue
+			}
+			if err := c.deleteAttachment(key, a); err != nil {
+				return fmt.Errorf("attached %v but couldn't delete the copy it replaces, %v: %w", u.Filename, a.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expandGlobs replaces each path that is a glob pattern, such as
+// 'logs/*.gz', with the files it matches in sorted order, so patterns work
+// even where the shell doesn't expand them, as on Windows. Paths that exist
+// as given are never treated as patterns.
+func expandGlobs(paths []string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		if path == "-" || isRemoteSource(path) || !strings.ContainsAny(path, "*?[") {
+			expanded = append(expanded, path)
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			expanded = append(expanded, path)
+			continue
+		}
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %v: %w", path, err)
+		}
+		var files []string
+		for _, m := range matches {
+			if info, err := os.Stat(m); err == nil && !info.IsDir() {
+				files = append(files, m)
+			}
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no files match %v", path)
+		}
+		sort.Strings(files)
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+// attachStdin uploads standard input to the issue as name, copying it to
+// standard output as it is read when tee is set. It also returns the SHA-256
+// of what was read, since stdin can't be read again to hash it.
+func attachStdin(config *Config, key, name string, tee bool) ([]Attachment, string, error) {
+	var r io.Reader = os.Stdin
+	if tee {
+		r = io.TeeReader(r, os.Stdout)
+	}
+	hr := newHashingReader(r)
+	attachments, err := attachFile(config, key, name, hr)
+	return attachments, hr.sum(), err
+}
+
+// attachSource uploads the attachment being fetched from a URL to the issue
+// as name, streaming it from one to the other. It also returns the SHA-256
+// of what was fetched, since it isn't kept to hash.
+func attachSource(config *Config, key, name string, source *remoteSource) ([]Attachment, string, error) {
+	hr := newHashingReader(source)
+	attachments, err := attachFile(config, key, name, hr)
+	return attachments, hr.sum(), err
+}
+
+// attachDir uploads an archive of the files under the directory at path that
+// f selects to the issue as name, building it as it is uploaded. It also
+// returns the archive's SHA-256, since the archive isn't kept to hash.
+func attachDir(config *Config, key, path, name, format string, f fileFilter) ([]Attachment, string, error) {
+	r, err := archiveDir(path, format, f)
+	if err != nil {
+		return nil, "", err
+	}
+	defer r.Close()
+	hr := newHashingReader(r)
+	attachments, err := attachFile(config, key, name, hr)
+	return attachments, hr.sum(), err
+}
+
+// attachFile uploads r to the issue as filename and records the upload in
+// the local history. Every command that uploads goes through attachFile, so
+// this is where content is checked before it leaves the machine. Streams,
+// which can't be read twice, are spooled to a temporary file first so that
+// nothing is sent until every check has passed.
+func attachFile(config *Config, key, filename string, r io.Reader) ([]Attachment, error) {
+	var checks []func(io.Reader) error
+	if !config.AllowSecrets {
+		checks = append(checks, func(r io.Reader) error {
+			return checkSecrets(filename, r, config.SecretPatterns)
+		})
+	}
+	if config.Antivirus.enabled() {
+		checks = append(checks, func(r io.Reader) error {
+			return config.Antivirus.check(filename, r)
+		})
+	}
+	if len(checks) == 0 {
+		return sendFile(config, key, filename, r)
+	}
+	rs, ok := rewindable(r)
+	if !ok {
+		spooled, cleanup, err := spool(r)
+		if err != nil {
+			return nil, err
+		}
+		defer cleanup()
+		rs = spooled
+	}
+	for _, check := range checks {
+		if err := check(rs); err != nil {
+			return nil, err
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("error rewinding attachment: %w", err)
+		}
+	}
+	return sendFile(config, key, filename, rs)
+}
+
+// sendFile compresses r as configured and uploads it to the issue as
+// filename, split into parts when it is too large and -split is set.
+func sendFile(config *Config, key, filename string, r io.Reader) ([]Attachment, error) {
+	format, err := config.compression(r)
+	if err != nil {
+		return nil, err
+	}
+	if format != "" {
+		before := remaining(r)
+		compressed, cleanup, err := compress(r, format)
+		if err != nil {
+			return nil, fmt.Errorf("error compressing %v: %w", filename, err)
+		}
+		defer cleanup()
+		if before >= 0 {
+			fmt.Fprintf(os.Stderr, "compressed %v: %v -> %v\n", filename, formatSize(before), formatSize(remaining(compressed)))
+		}
+		r, filename = compressed, filename+compressExtensions[format]
+	}
+
+	contentType := config.contentType
+	if contentType == "" {
+		head, pr, err := peek(r, sniffLen)
+		if err != nil {
+			return nil, err
+		}
+		r, contentType = pr, detectContentType(filename, head)
+	}
+
+	size := remaining(r)
+	if limit := config.client().uploadLimit(); limit > 0 && size > limit {
+		if config.Split {
+			return attachParts(config, key, filename, r, size, limit)
+		}
+		return nil, &exitError{code: exitTooLarge, err: fmt.Errorf("%v is %v, larger than the %v Jira accepts per attachment; -compress or -split may bring it under the limit", filename, formatSize(size), formatSize(limit))}
+	}
+	return upload(config, key, filename, contentType, r, size)
+}
+
+// upload sends size bytes read from r to the issue as filename of
+// contentType, showing its progress and recording it in the upload history.
+func upload(config *Config, key, filename, contentType string, r io.Reader, size int64) ([]Attachment, error) {
+	t := uploads.begin(filename, size)
+	attachments, err := config.client().attach(key, filename, contentType, &progressReader{r: config.throttle(r), p: uploads, t: t}, size)
+	uploads.end(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordHistory(key, attachments); err != nil {
+		warnf("unable to record upload history: %v", err)
+	}
+	return attachments, nil
+}
+
+// rewindable returns r as an io.ReadSeeker positioned at its current offset
+// so that it can be read more than once, or false when r can't seek, such
+// as when it is a pipe.
+func rewindable(r io.Reader) (io.ReadSeeker, bool) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		if offset, err := rs.Seek(0, io.SeekCurrent); err == nil {
+			return &offsetReadSeeker{rs: rs, base: offset}, true
+		}
+	}
+	return nil, false
+}
+
+// spool copies r, a stream that can only be read once, to a temporary file
+// readable only by the user, which cleanup removes.
+func spool(r io.Reader) (io.ReadSeeker, func(), error) {
+	tmp, err := ioutil.TempFile("", "jiraattach-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error buffering attachment: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("error buffering attachment: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("error buffering attachment: %w", err)
+	}
+	return tmp, cleanup, nil
+}
+
+// offsetReadSeeker makes base the start of rs, so seeking to the start
+// returns to where rs was when it was handed to rewindable.
+type offsetReadSeeker struct {
+	rs   io.ReadSeeker
+	base int64
+}
+
+func (o *offsetReadSeeker) Read(p []byte) (int, error) {
+	return o.rs.Read(p)
+}
+
+func (o *offsetReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	if whence == io.SeekStart {
+		offset += o.base
+	}
+	n, err := o.rs.Seek(offset, whence)
+	return n - o.base, err
+}
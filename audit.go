@@ -0,0 +1,197 @@
+package jiraattach
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/user"
+	"sync"
+	"time"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+// auditEntry records a single write operation against Jira. Each entry holds
+// the SHA-256 of the line before it, so editing or removing a line breaks
+// the chain for every entry that follows.
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	LocalUser string    `json:"local_user"`
+	Host      string    `json:"host"`
+	Action    string    `json:"action"`
+	Issue     string    `json:"issue"`
+	Target    string    `json:"target,omitempty"`
+	SHA256    string    `json:"sha256,omitempty"`
+	Result    string    `json:"result"`
+	Prev      string    `json:"prev"`
+}
+
+// auditLog appends entries to an append-only JSONL file.
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	user string
+}
+
+// record appends an entry for action to the log. A nil auditLog records
+// nothing, so callers don't need to check whether auditing is enabled.
+func (l *auditLog) record(action, issue, target, sum string, opErr error) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := auditEntry{
+		Time:   time.Now().UTC(),
+		User:   l.user,
+		Action: action,
+		Issue:  issue,
+		Target: target,
+		SHA256: sum,
+		Result: "ok",
+	}
+	if u, err := user.Current(); err == nil {
+		entry.LocalUser = u.Username
+	}
+	entry.Host, _ = os.Hostname()
+	if opErr != nil {
+		entry.Result = redact(opErr.Error())
+	}
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	defer f.Close()
+	last, err := lastLine(f)
+	if err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+	entry.Prev = hashLine(last)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error encoding audit entry: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing audit log: %w", err)
+	}
+	return f.Sync()
+}
+
+// lastLine returns the last complete line of f without its newline.
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	const window = 64 * 1024
+	offset := info.Size() - window
+	if offset < 0 {
+		offset = 0
+	}
+	buf := make([]byte, info.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = bytes.TrimRight(buf, "\n")
+	if i := bytes.LastIndexByte(buf, '\n'); i >= 0 {
+		buf = buf[i+1:]
+	}
+	return buf, nil
+}
+
+// hashLine returns the chain hash of a log line. The first entry of a log
+// chains from the hash of an empty line.
+func hashLine(line []byte) string {
+	sum := sha256.Sum256(line)
+	return hex.EncodeToString(sum[:])
+}
+
+// hashingReader computes the SHA-256 of everything read through it.
+type hashingReader struct {
+	r io.Reader
+	h hash.Hash
+	n int64
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{r: r, h: sha256.New()}
+}
+
+func (r *hashingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+	return n, err
+}
+
+// Rewind starts the hash over along with the underlying reader.
+func (r *hashingReader) Rewind() error {
+	if err := jira.Rewind(r.r, r.n); err != nil {
+		return err
+	}
+	r.h.Reset()
+	r.n = 0
+	return nil
+}
+
+// sum returns the hex encoded SHA-256 of the data read so far.
+func (r *hashingReader) sum() string {
+	return hex.EncodeToString(r.h.Sum(nil))
+}
+
+const auditUsage = `usage: jiraattach audit [path]
+
+  Verify that the audit log has not been modified.
+`
+
+// runAudit implements the audit command, verifying the hash chain of the
+// audit log.
+func runAudit(config *Config, args []string) error {
+	fs := newFlagSet("audit", auditUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	path := config.AuditLog
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+	if path == "" {
+		return fmt.Errorf("no audit log configured")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening audit log: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	prev, n := hashLine(nil), 0
+	for scanner.Scan() {
+		n++
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return fmt.Errorf("audit log is damaged at line %d: %w", n, err)
+		}
+		if entry.Prev != prev {
+			return fmt.Errorf("audit log chain is broken at line %d, earlier entries were modified or removed", n)
+		}
+		prev = hashLine(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading audit log: %w", err)
+	}
+	fmt.Printf("%v: %d entries, chain intact\n", path, n)
+	return nil
+}
@@ -0,0 +1,180 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Authentication types accepted by auth_type. Basic and api_token both send
+// auth as "user:secret" with basic authentication, api_token being an
+// Atlassian account email and API token for Jira Cloud. Pat and oauth send
+// auth as a bearer token: a Data Center Personal Access Token or an OAuth
+// 2.0 access token, which when auth is empty comes from logging in with the
+// login command.
+const (
+	authBasic    = "basic"
+	authAPIToken = "api_token"
+	authPAT      = "pat"
+	authOAuth    = "oauth"
+)
+
+// parseAuth splits the auth setting into basic authentication credentials or
+// a bearer token according to authType. An empty authType is basic, as it
+// was before auth_type existed.
+func parseAuth(authType, auth string) (user, pass, token string, err error) {
+	switch authType {
+	case "", authBasic, authAPIToken:
+		if strings.Contains(auth, ":") {
+			parts := strings.SplitN(auth, ":", 2)
+			return parts[0], parts[1], "", nil
+		}
+		switch authType {
+		case authBasic:
+			return "", "", "", fmt.Errorf("auth must be 'username:password' when auth_type is basic")
+		case authAPIToken:
+			return "", "", "", fmt.Errorf("auth must be 'email:api-token' when auth_type is api_token")
+		}
+		return "", "", "", nil
+	case authOAuth:
+		// Without a token in auth, the one saved by login is used.
+		return "", "", auth, nil
+	case authPAT:
+		if auth == "" {
+			return "", "", "", fmt.Errorf("auth must be the token when auth_type is %v", authType)
+		}
+		return "", "", auth, nil
+	}
+	return "", "", "", fmt.Errorf("invalid auth_type %q, expected basic, api_token, pat or oauth", authType)
+}
+
+// validateAuth checks the credentials of the top level settings and of every
+// profile.
+func (c *Config) validateAuth() error {
+	if _, _, _, err := parseAuth(c.AuthType, c.Auth); err != nil {
+		return err
+	}
+	for name, p := range c.Profiles {
+		if _, _, _, err := parseAuth(p.authType(c), p.auth(c)); err != nil {
+			return fmt.Errorf("profile %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+const loginUsage = `usage: jiraattach login [-no-browser]
+
+  Ask for the credentials for jira_url, check them and save them in the OS
+  keyring: the macOS Keychain, the Windows Credential Manager or the Secret
+  Service through secret-tool. They are then used whenever auth is empty.
+  When auth_type is oauth, log in to the Jira Cloud site with OAuth 2.0
+  instead: a browser is opened to grant access, the tokens are saved in the
+  state directory and refreshed as they expire, and requests then go through
+  api.atlassian.com. With -no-browser the authorization URL is printed
+  instead.
+`
+
+// runLogin implements the login command. For OAuth it runs the browser
+// based login, otherwise it asks for credentials, checks them against Jira
+// and saves them in the OS keyring.
+func runLogin(config *Config, args []string) error {
+	fs := newFlagSet("login", loginUsage)
+	nobrowser := fs.Bool("no-browser", false, "print the OAuth authorization URL instead of opening a browser")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if config.JiraURL == "" {
+		return fmt.Errorf("jira_url must be set to the site to log in to")
+	}
+	if config.AuthType == authOAuth {
+		return oauthLogin(config, *nobrowser)
+	}
+
+	auth, err := promptAuth(config.AuthType)
+	if err != nil {
+		return err
+	}
+	me, err := checkAuth(config, auth)
+	if err != nil {
+		return err
+	}
+	if err := keyringSet(config.JiraURL, auth); err != nil {
+		return fmt.Errorf("%w, set auth in the config file instead", err)
+	}
+	config.Auth, config.c = auth, nil
+	fmt.Printf("Logged in to %v as %v\n", config.JiraURL, me.DisplayName)
+	return nil
+}
+
+// promptAuth asks for credentials of the given auth type and returns them in
+// the form of the auth setting.
+func promptAuth(authType string) (string, error) {
+	if authType == authPAT {
+		return readSecret("Personal access token: ")
+	}
+	userPrompt, secretPrompt := "Username: ", "Password: "
+	if authType == authAPIToken {
+		userPrompt, secretPrompt = "Email: ", "API token: "
+	}
+	user, err := (&plainReader{out: os.Stderr}).readLine(userPrompt)
+	if err != nil {
+		return "", err
+	}
+	secret, err := readSecret(secretPrompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(user) + ":" + secret, nil
+}
+
+// checkAuth checks that auth authenticates with the Jira instance of config
+// and returns the user it authenticates as.
+func checkAuth(config *Config, auth string) (*User, error) {
+	check := *config
+	check.Auth, check.c, check.profileClients = auth, nil, nil
+	me, err := check.client().myself()
+	if err != nil {
+		return nil, fmt.Errorf("error checking credentials for %v: %w", config.JiraURL, err)
+	}
+	return me, nil
+}
+
+const logoutUsage = `usage: jiraattach logout
+
+  Forget the credentials or OAuth tokens saved by login.
+`
+
+// runLogout implements the logout command, forgetting the credentials or
+// OAuth tokens saved by login.
+func runLogout(config *Config, args []string) error {
+	fs := newFlagSet("logout", logoutUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if config.JiraURL == "" {
+		return fmt.Errorf("jira_url must be set to the site to log out of")
+	}
+	if config.AuthType == authOAuth {
+		token, err := loadOAuthToken(config.JiraURL)
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			return fmt.Errorf("not logged in to %v", config.JiraURL)
+		}
+		if err := saveOAuthToken(config.JiraURL, nil); err != nil {
+			return err
+		}
+	} else {
+		err := keyringDelete(config.JiraURL)
+		if err == errNotInKeyring {
+			return fmt.Errorf("not logged in to %v", config.JiraURL)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	config.c, config.profileClients = nil, nil
+	fmt.Printf("Logged out of %v\n", config.JiraURL)
+	return nil
+}
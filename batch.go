@@ -0,0 +1,335 @@
+package jiraattach
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// batchQueueFile is the state file holding the work queue of the most recent
+// batch run, so an interrupted run can be resumed.
+const batchQueueFile = "batch.json"
+
+// Batch item states.
+const (
+	batchPending = "pending"
+	batchDone    = "done"
+	batchFailed  = "failed"
+)
+
+// batchItem is one file to attach to one issue, optionally under another
+// name and with a comment.
+type batchItem struct {
+	Issue      string `json:"issue"`
+	Path       string `json:"path"`
+	Name       string `json:"name,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Attachment string `json:"attachment,omitempty"`
+	URL        string `json:"url,omitempty"`
+}
+
+// batchQueue is the persisted state of a batch run.
+type batchQueue struct {
+	Manifest string      `json:"manifest"`
+	Started  time.Time   `json:"started"`
+	Items    []batchItem `json:"items"`
+}
+
+// count returns the number of items in the given state.
+func (q *batchQueue) count(status string) int {
+	n := 0
+	for _, item := range q.Items {
+		if item.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+const batchUsage = `usage: jiraattach batch [-concurrency=n] [-results=file] manifest | batch
+  -resume
+
+  Attach the files listed in a manifest, a JSON list of {"issue": "KEY",
+  "path": "file", "name": "as.txt", "comment": "text"} objects, or a CSV
+  file with issue, path, name and comment columns, where name and comment
+  are optional and paths are relative to the manifest. Each file is attached
+  under its name, then its comment is posted. -concurrency attaches that
+  many files at once, and -results writes the status, error and attachment
+  of every file to a JSON file, or CSV with a .csv extension. The progress
+  of the run is saved in the state directory after every file, so an
+  interrupted run, or one where some files failed, can be continued with
+  -resume without uploading the files already attached again, continuing
+  files attached with -split from their first part not yet attached.
+`
+
+// runBatch implements the batch command, attaching the files listed in a
+// manifest to their issues.
+func runBatch(config *Config, args []string) error {
+	fs := newFlagSet("batch", batchUsage)
+	resume := fs.Bool("resume", false, "resume the last batch run, retrying its pending and failed files")
+	concurrency := fs.Int("concurrency", config.concurrency(), "number of files to attach at once")
+	results := fs.String("results", "", "write the outcome of every file to this JSON or, with a .csv extension, CSV file")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *concurrency < 1 {
+		return usageErrorf("-concurrency must be at least 1")
+	}
+	config.resume = *resume
+
+	q := &batchQueue{}
+	if err := loadState(batchQueueFile, q); err != nil {
+		return fmt.Errorf("error reading batch queue: %w", err)
+	}
+	if *resume {
+		if len(q.Items) == 0 || q.count(batchDone) == len(q.Items) {
+			return fmt.Errorf("no unfinished batch run to resume")
+		}
+		fmt.Fprintf(os.Stderr, "resuming %v from %v, %d of %d files already attached\n",
+			q.Manifest, q.Started.Format("2006-01-02 15:04"), q.count(batchDone), len(q.Items))
+	} else {
+		if fs.NArg() < 1 {
+			return usageErrorf("manifest is required")
+		}
+		if n := q.count(batchPending) + q.count(batchFailed); n > 0 {
+			warnf("discarding %d unfinished files from the batch run of %v", n, q.Manifest)
+		}
+		items, err := readBatchManifest(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		q = &batchQueue{Manifest: fs.Arg(0), Started: time.Now(), Items: items}
+		if abs, err := filepath.Abs(q.Manifest); err == nil {
+			q.Manifest = abs
+		}
+	}
+	if err := saveState(batchQueueFile, q); err != nil {
+		return fmt.Errorf("error saving batch queue: %w", err)
+	}
+
+	var keys []string
+	for _, item := range q.Items {
+		keys = append(keys, item.Issue)
+	}
+	config.routeAll(keys)
+
+	var (
+		mu      sync.Mutex
+		saveErr error
+		wg      sync.WaitGroup
+	)
+	work := make(chan int)
+	for w := 0; w < *concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				mu.Lock()
+				item := q.Items[i]
+				mu.Unlock()
+				err := attachBatchItem(config.route(item.Issue), &item)
+				mu.Lock()
+				if err != nil {
+					item.Status, item.Error = batchFailed, redact(err.Error())
+					fmt.Fprintf(os.Stderr, "failed    %v %v: %v\n", item.Issue, redact(item.Path), item.Error)
+				} else {
+					item.Status, item.Error = batchDone, ""
+					fmt.Fprintf(os.Stderr, "attached  %v %v\n", item.Issue, redact(item.Path))
+				}
+				q.Items[i] = item
+				if err := saveState(batchQueueFile, q); err != nil && saveErr == nil {
+					saveErr = err
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i, item := range q.Items {
+		if item.Status != batchDone {
+			work <- i
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if *results != "" {
+		if err := writeBatchResults(*results, q.Items); err != nil {
+			return fmt.Errorf("error writing results, %v: %w", *results, err)
+		}
+	}
+	if saveErr != nil {
+		return fmt.Errorf("error saving batch queue: %v", saveErr)
+	}
+	if n := q.count(batchFailed); n > 0 {
+		return partialError(fmt.Errorf("%d of %d files could not be attached, run batch -resume to retry them", n, len(q.Items)), n, len(q.Items), nil)
+	}
+	return nil
+}
+
+// attachBatchItem attaches item's file to its issue and posts its comment,
+// recording the attachment on item. An item whose file was attached by an
+// earlier run but whose comment failed only has its comment retried.
+func attachBatchItem(config *Config, item *batchItem) error {
+	if item.Attachment == "" {
+		var (
+			attachments []Attachment
+			err         error
+		)
+		if isRemoteSource(item.Path) {
+			var source *remoteSource
+			if source, err = config.openSource(item.Path); err != nil {
+				return err
+			}
+			defer source.Close()
+			name := item.Name
+			if name == "" {
+				name = source.name
+			}
+			attachments, _, err = attachSource(config, item.Issue, name, source)
+		} else {
+			name := item.Name
+			if name == "" {
+				name = sanitizeName(filepath.Base(item.Path))
+			}
+			attachments, err = attachPath(config, item.Issue, item.Path, name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(attachments) > 0 {
+			item.Attachment, item.URL = attachments[0].ID, attachments[0].Content
+		}
+	}
+	if item.Comment != "" {
+		if _, err := config.client().comment(item.Issue, item.Comment, commentOptions{}); err != nil {
+			return fmt.Errorf("attached but error commenting on %v: %w", item.Issue, err)
+		}
+	}
+	return nil
+}
+
+// batchColumns are the columns of a CSV manifest, in the order they are read
+// when it has no header row.
+var batchColumns = []string{"issue", "path", "name", "comment"}
+
+// readBatchManifest reads the list of files to attach from the manifest at
+// path: a JSON list of objects, or with a .csv extension a CSV file with one
+// file per row. Relative paths are relative to the manifest.
+func readBatchManifest(path string) ([]batchItem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest: %w", err)
+	}
+	var items []batchItem
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		items, err = parseBatchCSV(string(data))
+	} else {
+		err = json.Unmarshal(data, &items)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading manifest, %v: %w", path, err)
+	}
+	for i := range items {
+		if items[i].Issue == "" || items[i].Path == "" {
+			return nil, fmt.Errorf("manifest entry %d needs an issue and a path", i+1)
+		}
+		if items[i].Name != "" {
+			if err := checkName(items[i].Name); err != nil {
+				return nil, fmt.Errorf("manifest entry %d has an invalid name: %w", i+1, err)
+			}
+		}
+		if !filepath.IsAbs(items[i].Path) && !isRemoteSource(items[i].Path) {
+			items[i].Path = filepath.Join(filepath.Dir(path), items[i].Path)
+		}
+		items[i].Status = batchPending
+	}
+	return items, nil
+}
+
+// parseBatchCSV reads a CSV manifest. A first row naming the columns, in any
+// order, is a header; otherwise the columns are read as batchColumns.
+func parseBatchCSV(data string) ([]batchItem, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	columns := batchColumns
+	if len(rows) > 0 && strings.EqualFold(strings.TrimSpace(rows[0][0]), "issue") {
+		columns = nil
+		for _, name := range rows[0] {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if !contains(batchColumns, name) {
+				return nil, fmt.Errorf("unknown column %q, expected %v", name, strings.Join(batchColumns, ", "))
+			}
+			columns = append(columns, name)
+		}
+		rows = rows[1:]
+	}
+	var items []batchItem
+	for _, row := range rows {
+		if len(row) == 1 && strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		var item batchItem
+		for i, value := range row {
+			if i >= len(columns) {
+				return nil, fmt.Errorf("row %d has more than %d columns", len(items)+1, len(columns))
+			}
+			switch columns[i] {
+			case "issue":
+				item.Issue = strings.TrimSpace(value)
+			case "path":
+				item.Path = value
+			case "name":
+				item.Name = value
+			case "comment":
+				item.Comment = value
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// writeBatchResults writes the outcome of every item to path, as CSV when it
+// has a .csv extension and as JSON otherwise.
+func writeBatchResults(path string, items []batchItem) error {
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		data, err := json.MarshalIndent(items, "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(path, append(data, '\n'), 0644)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := writeBatchCSV(f, items); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func writeBatchCSV(w io.Writer, items []batchItem) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"issue", "path", "name", "status", "error", "attachment", "url"})
+	for _, item := range items {
+		cw.Write([]string{item.Issue, item.Path, item.Name, item.Status, item.Error, item.Attachment, item.URL})
+	}
+	cw.Flush()
+	return cw.Error()
+}
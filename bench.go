@@ -0,0 +1,84 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const benchUsage = `usage: jiraattach bench [-size=n] [-count=n] key
+
+  Measure latency, upload throughput and retries to a Jira Issue by
+  uploading a synthetic attachment of the given size, such as 100MB, and
+  deleting it again.
+`
+
+// runBench implements the bench command, measuring upload throughput to an
+// issue with a synthetic attachment that is deleted afterwards.
+func runBench(config *Config, args []string) error {
+	fs := newFlagSet("bench", benchUsage)
+	size := fs.String("size", "10MB", "size of the synthetic attachment")
+	count := fs.Int("count", 1, "number of uploads to make")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+	n, err := parseSize(*size)
+	if err != nil {
+		return err
+	}
+
+	c := config.client()
+
+	// Latency is measured with a request that transfers next to nothing.
+	start := time.Now()
+	if _, err := c.issue(key, "summary"); err != nil {
+		return fmt.Errorf("error fetching issue %v: %w", key, err)
+	}
+	fmt.Printf("latency     %v\n", time.Since(start).Round(time.Millisecond))
+
+	var total time.Duration
+	for i := 0; i < *count; i++ {
+		// Random content keeps compressing proxies from flattering the result.
+		// It's held in memory so that retried uploads can send it again.
+		data := make([]byte, n)
+		rand.New(rand.NewSource(time.Now().UnixNano())).Read(data)
+		name := fmt.Sprintf("jiraattach-bench-%d.bin", time.Now().UnixNano())
+
+		start, retries := time.Now(), atomic.LoadInt64(&c.retries)
+		attachments, err := c.attach(key, name, "", bytes.NewReader(data), n)
+		elapsed := time.Since(start)
+		if err != nil {
+			return fmt.Errorf("error uploading %v: %w", name, err)
+		}
+		total += elapsed
+		fmt.Printf("upload %-4d %v in %v, %v/s, %d retries\n", i+1, formatSize(n), elapsed.Round(time.Millisecond), throughput(n, elapsed), atomic.LoadInt64(&c.retries)-retries)
+
+		for _, a := range attachments {
+			if err := c.deleteAttachment(key, a); err != nil {
+				warnf("unable to delete benchmark attachment %v: %v", a.ID, err)
+			}
+		}
+	}
+	if *count > 1 {
+		fmt.Printf("average     %v/s\n", throughput(n*int64(*count), total))
+	}
+	return nil
+}
+
+// throughput formats the rate of transferring n bytes in d.
+func throughput(n int64, d time.Duration) string {
+	if d <= 0 {
+		return formatSize(n)
+	}
+	return formatSize(int64(float64(n) / d.Seconds()))
+}
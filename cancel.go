@@ -0,0 +1,71 @@
+package jiraattach
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// context returns the context of the running command, which is cancelled by
+// Ctrl-C or SIGTERM and once -timeout passes.
+func (s *settings) context() context.Context {
+	if s == nil || s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// runCancellable runs cmd with a context that SIGINT and SIGTERM cancel,
+// along with the requests made under it, and that ends after -timeout. The
+// command gets to report what it had done before stopping; a second signal
+// exits straight away.
+func runCancellable(config *Config, cmd func(*Config, []string) error, args []string) error {
+	s := config.settings
+	if s == nil {
+		return cmd(config, args)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if s.timeout > 0 {
+		var stop context.CancelFunc
+		ctx, stop = context.WithTimeout(ctx, s.timeout)
+		defer stop()
+	}
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+		fmt.Fprintln(os.Stderr, "interrupted, stopping; interrupt again to quit now")
+		cancel()
+		select {
+		case <-signals:
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	prev := s.ctx
+	s.ctx = ctx
+	defer func() { s.ctx = prev }()
+	err := cmd(config, args)
+	if err == nil {
+		return nil
+	}
+	switch ctx.Err() {
+	case context.Canceled:
+		return &exitError{code: exitInterrupted, err: fmt.Errorf("interrupted")}
+	case context.DeadlineExceeded:
+		return &exitError{code: exitTimeout, err: fmt.Errorf("timed out after %v", s.timeout)}
+	}
+	return err
+}
@@ -0,0 +1,195 @@
+package jiraattach
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// capabilitiesFile is the state file caching instance capabilities, keyed
+// by Jira base URL.
+const capabilitiesFile = "capabilities.json"
+
+// defaultCapabilitiesTTL is how long discovered capabilities are trusted
+// when capabilities_ttl isn't configured.
+const defaultCapabilitiesTTL = 24 * time.Hour
+
+// capabilities describes what a Jira instance supports. It is discovered
+// with a few requests and cached so repeated invocations can skip them.
+type capabilities struct {
+	Fetched            time.Time         `json:"fetched"`
+	DeploymentType     string            `json:"deployment_type"`
+	Version            string            `json:"version"`
+	APIVersion         int               `json:"api_version"`
+	ADF                bool              `json:"adf"`
+	AttachmentsEnabled bool              `json:"attachments_enabled"`
+	UploadLimit        int64             `json:"upload_limit"`
+	ProjectTypes       map[string]string `json:"project_types"`
+}
+
+// cloud reports whether the instance is Jira Cloud.
+func (c *capabilities) cloud() bool {
+	return strings.EqualFold(c.DeploymentType, "Cloud")
+}
+
+// capabilities returns the instance capabilities, from the cache when they
+// were discovered within the TTL. Parallel uploads share the discovery.
+func (c *client) capabilities() (*capabilities, error) {
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+	if c.caps != nil {
+		return c.caps, nil
+	}
+	cache := map[string]*capabilities{}
+	if err := loadState(capabilitiesFile, &cache); err != nil {
+		warnf("ignoring capability cache: %v", err)
+	}
+	if caps, ok := cache[c.baseURL]; ok && time.Since(caps.Fetched) < c.capsTTL {
+		c.caps = caps
+		return caps, nil
+	}
+
+	caps, err := c.discover()
+	if err != nil {
+		return nil, err
+	}
+	c.caps = caps
+	c.saveCapabilities()
+	return caps, nil
+}
+
+// discover queries the instance for its capabilities.
+func (c *client) discover() (*capabilities, error) {
+	var info struct {
+		DeploymentType string `json:"deploymentType"`
+		Version        string `json:"version"`
+	}
+	req, err := c.newRequest("GET", "/rest/api/2/serverInfo", nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.do(req, &info); err != nil {
+		return nil, fmt.Errorf("error fetching server info: %w", err)
+	}
+
+	var meta struct {
+		Enabled     bool  `json:"enabled"`
+		UploadLimit int64 `json:"uploadLimit"`
+	}
+	if req, err = c.newRequest("GET", "/rest/api/2/attachment/meta", nil); err != nil {
+		return nil, err
+	}
+	if err := c.do(req, &meta); err != nil {
+		return nil, fmt.Errorf("error fetching attachment settings: %w", err)
+	}
+
+	caps := &capabilities{
+		Fetched:            time.Now(),
+		DeploymentType:     info.DeploymentType,
+		Version:            info.Version,
+		APIVersion:         2,
+		AttachmentsEnabled: meta.Enabled,
+		UploadLimit:        meta.UploadLimit,
+		ProjectTypes:       map[string]string{},
+	}
+	// Only Jira Cloud offers version 3 of the API, whose comments must be
+	// written in the Atlassian Document Format.
+	if caps.cloud() {
+		caps.APIVersion = 3
+		caps.ADF = true
+	}
+	return caps, nil
+}
+
+// uploadLimit returns the largest attachment the instance accepts, or 0
+// when it has no limit or the limit can't be discovered, leaving Jira to
+// refuse files that are too large.
+func (c *client) uploadLimit() int64 {
+	caps, err := c.capabilities()
+	if err != nil {
+		return 0
+	}
+	return caps.UploadLimit
+}
+
+// projectType returns the type of the project, such as software or
+// service_desk, caching it with the other capabilities.
+func (c *client) projectType(project string) (string, error) {
+	caps, err := c.capabilities()
+	if err != nil {
+		return "", err
+	}
+	if t, ok := caps.ProjectTypes[project]; ok {
+		return t, nil
+	}
+	req, err := c.newRequest("GET", "/rest/api/2/project/"+url.PathEscape(project), nil)
+	if err != nil {
+		return "", err
+	}
+	var p struct {
+		ProjectTypeKey string `json:"projectTypeKey"`
+	}
+	if err := c.do(req, &p); err != nil {
+		return "", fmt.Errorf("error fetching project %v: %w", project, err)
+	}
+	caps.ProjectTypes[project] = p.ProjectTypeKey
+	c.saveCapabilities()
+	return p.ProjectTypeKey, nil
+}
+
+// saveCapabilities writes the client's capabilities to the cache. Failing
+// to do so only costs a little time on the next run, so it isn't an error.
+func (c *client) saveCapabilities() {
+	cache := map[string]*capabilities{}
+	loadState(capabilitiesFile, &cache)
+	cache[c.baseURL] = c.caps
+	if err := saveState(capabilitiesFile, cache); err != nil {
+		warnf("unable to cache capabilities: %v", err)
+	}
+}
+
+const capabilitiesUsage = `usage: jiraattach capabilities [-refresh] [project...]
+
+  Show what the Jira instance supports: deployment type, API version,
+  whether comments need the Atlassian Document Format, the attachment size
+  limit and the type of each project given. Capabilities are cached for
+  capabilities_ttl.
+`
+
+// runCapabilities implements the capabilities command, printing what the
+// Jira instance supports.
+func runCapabilities(config *Config, args []string) error {
+	fs := newFlagSet("capabilities", capabilitiesUsage)
+	refresh := fs.Bool("refresh", false, "discover capabilities again instead of using the cache")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	c := config.client()
+	if *refresh {
+		c.capsTTL = 0
+	}
+	caps, err := c.capabilities()
+	if err != nil {
+		return err
+	}
+	for _, project := range fs.Args() {
+		if _, err := c.projectType(project); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("deployment   %v %v\n", caps.DeploymentType, caps.Version)
+	fmt.Printf("api version  %v\n", caps.APIVersion)
+	fmt.Printf("adf comments %v\n", caps.ADF)
+	fmt.Printf("attachments  %v\n", caps.AttachmentsEnabled)
+	if caps.UploadLimit > 0 {
+		fmt.Printf("upload limit %v\n", formatSize(caps.UploadLimit))
+	}
+	for project, t := range caps.ProjectTypes {
+		fmt.Printf("project      %v %v\n", project, t)
+	}
+	fmt.Printf("discovered   %v\n", caps.Fetched.Format(time.RFC3339))
+	return nil
+}
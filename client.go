@@ -0,0 +1,325 @@
+package jiraattach
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+// Version identifies the build in the User-Agent header. The command sets
+// it from its own version, see cmd/jiraattach.
+var Version = "dev"
+
+// defaultUserAgent identifies jiraattach to Jira, so that administrators can
+// tell its requests apart from other API clients.
+func defaultUserAgent() string {
+	return fmt.Sprintf("jiraattach/%v (%v/%v)", Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// client is the Jira client used by the commands. It adds the configured
+// credentials, retries, capability discovery and audit logging to a
+// jira.Client, which makes the requests.
+type client struct {
+	baseURL string
+	// siteURL is the site as it is browsed, which differs from baseURL
+	// when requests go through the OAuth API gateway.
+	siteURL string
+	user    string
+	pass    string
+	token   string
+	oauth   *oauthSession
+	http    *http.Client
+	audit   *auditLog
+	caps    *capabilities
+	capsMu  sync.Mutex
+	capsTTL time.Duration
+	retry   RetryConfig
+	retries int64
+	agent   string
+
+	commentFormat string
+	insecureHTTP  bool
+	headers       map[string]string
+
+	// api makes the requests, through doWithRetry.
+	api *jira.Client
+	// settings holds the context of the running command.
+	settings *settings
+}
+
+func newClient(config *Config) *client {
+	user, pass, token, _ := parseAuth(config.AuthType, config.Auth)
+	c := &client{
+		baseURL: strings.TrimSuffix(config.JiraURL, "/"),
+		siteURL: strings.TrimSuffix(config.JiraURL, "/"),
+		user:    user,
+		pass:    pass,
+		token:   token,
+		http:    &http.Client{Transport: newTransport(config)},
+	}
+	if token != "" {
+		addCredential(token)
+	} else {
+		addBasicAuth(user, pass)
+	}
+	if config.AuthType == authOAuth && token == "" {
+		// Logged in sites are reached through the Atlassian API gateway.
+		c.oauth = &oauthSession{site: config.JiraURL, conf: config.OAuth}
+		var err error
+		if c.oauth.token, err = loadOAuthToken(config.JiraURL); err != nil {
+			warnf("%v", err)
+		}
+		if c.oauth.token != nil {
+			addCredential(c.oauth.token.AccessToken)
+			addCredential(c.oauth.token.RefreshToken)
+			c.baseURL = oauthAPIURL + c.oauth.token.CloudID
+		}
+		addCredential(config.OAuth.ClientSecret)
+	}
+	if s := config.settings; s != nil && (s.debug || s.trace) {
+		c.http.Transport = &debugTransport{next: c.http.Transport, bodies: s.trace}
+	}
+	if config.AuditLog != "" {
+		c.audit = &auditLog{path: config.AuditLog, user: user}
+	}
+	c.agent = config.UserAgent
+	if c.agent == "" {
+		c.agent = defaultUserAgent()
+	}
+	c.headers = map[string]string{}
+	for name, value := range config.Headers {
+		c.headers[name] = value
+	}
+	if s := config.settings; s != nil {
+		for _, h := range s.headers {
+			name, value, _ := parseHeader(h)
+			c.headers[name] = value
+		}
+	}
+	for _, value := range c.headers {
+		addCredential(value)
+	}
+	c.retry = config.Retry
+	c.insecureHTTP = config.AllowInsecureHTTP
+	c.commentFormat = config.CommentFormat
+	c.capsTTL = defaultCapabilitiesTTL
+	if config.CapabilitiesTTL != "" {
+		c.capsTTL, _ = parseAge(config.CapabilitiesTTL)
+	}
+	c.settings = config.settings
+	c.api = &jira.Client{
+		BaseURL:      c.baseURL,
+		HTTPClient:   doerFunc(c.doWithRetry),
+		Authenticate: c.authenticate,
+		UserAgent:    c.agent,
+	}
+	return c
+}
+
+// authenticate adds the configured credentials and extra headers to req,
+// refusing to send them over plain HTTP unless allow_insecure_http is set.
+func (c *client) authenticate(req *http.Request) error {
+	if err := checkInsecureHTTP(req.URL.String(), c.insecureHTTP); err != nil {
+		return err
+	}
+	switch {
+	case c.oauth != nil:
+		token, err := c.oauth.accessToken(req.Context(), c.http, c.agent)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	default:
+		req.SetBasicAuth(c.user, c.pass)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+// context returns the context of the running command.
+func (c *client) context() context.Context {
+	return c.settings.context()
+}
+
+// newRequest creates an authenticated request for the given API path. Absolute
+// URLs, such as attachment content links, are used as is.
+func (c *client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+	return c.api.NewRequest(c.context(), method, path, body)
+}
+
+// send sends req, retrying as configured and transparently decompressing
+// gzip encoded responses.
+func (c *client) send(req *http.Request) (*http.Response, error) {
+	return c.api.Send(req)
+}
+
+// do sends req and, when v is not nil, decodes the JSON response into v.
+func (c *client) do(req *http.Request, v interface{}) error {
+	return c.api.Do(req, v)
+}
+
+// attach uploads the contents of r, size bytes long or -1 when unknown, to
+// the issue as filename of contentType, guessed from filename when empty,
+// and returns the attachments Jira created, recording it in the audit log.
+func (c *client) attach(key, filename, contentType string, r io.Reader, size int64) ([]Attachment, error) {
+	hr := newHashingReader(r)
+	attachments, err := c.api.AttachFileWithType(c.context(), key, filename, contentType, hr, size)
+	if aerr := c.audit.record("attach", key, filename, hr.sum(), err); aerr != nil && err == nil {
+		return nil, fmt.Errorf("attachment uploaded but %v", aerr)
+	}
+	return attachments, err
+}
+
+// issue fetches the issue identified by key. When fields are given only
+// those fields are returned.
+func (c *client) issue(key string, fields ...string) (*Issue, error) {
+	return c.api.Issue(c.context(), key, fields...)
+}
+
+// myself returns the user the client is authenticated as.
+func (c *client) myself() (*User, error) {
+	return c.api.Myself(c.context())
+}
+
+// canAttach checks that the user may attach files to issues in the project.
+func (c *client) canAttach(project string) error {
+	q := url.Values{}
+	q.Set("projectKey", project)
+	return c.checkAttachPermission(q)
+}
+
+// canAttachTo checks that the user may attach files to the issue.
+func (c *client) canAttachTo(key string) error {
+	q := url.Values{}
+	q.Set("issueKey", key)
+	return c.checkAttachPermission(q)
+}
+
+// checkAttachPermission checks the Create Attachments permission in the
+// project or issue named by q.
+func (c *client) checkAttachPermission(q url.Values) error {
+	q.Set("permissions", "CREATE_ATTACHMENTS")
+	req, err := c.newRequest("GET", "/rest/api/2/mypermissions?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	var perms struct {
+		Permissions map[string]struct {
+			HavePermission bool `json:"havePermission"`
+		} `json:"permissions"`
+	}
+	if err := c.do(req, &perms); err != nil {
+		return err
+	}
+	if !perms.Permissions["CREATE_ATTACHMENTS"].HavePermission {
+		return &exitError{code: exitAuth, err: fmt.Errorf("the Create Attachments permission is missing")}
+	}
+	return nil
+}
+
+// attachments lists the attachments on the issue identified by key.
+func (c *client) attachments(key string) ([]Attachment, error) {
+	return c.api.ListAttachments(c.context(), key)
+}
+
+// comment adds a comment with the given wiki markup body to the issue.
+func (c *client) comment(key, body string, opts commentOptions) (*Comment, error) {
+	comment, err := c.postComment(key, body, opts)
+	sum := sha256.Sum256([]byte(body))
+	if aerr := c.audit.record("comment", key, "", hex.EncodeToString(sum[:]), err); aerr != nil && err == nil {
+		return nil, fmt.Errorf("comment posted but %v", aerr)
+	}
+	return comment, err
+}
+
+func (c *client) postComment(key, body string, opts commentOptions) (*Comment, error) {
+	if c.useADF() {
+		return c.postADFComment(key, body, opts)
+	}
+	return c.api.AddComment(c.context(), key, body, opts.jira())
+}
+
+// search returns every issue matching the JQL query, fetching the given
+// fields and following pagination until all results have been read.
+func (c *client) search(jql string, fields ...string) ([]Issue, error) {
+	return c.api.Search(c.context(), jql, fields...)
+}
+
+// comments returns every comment on the issue, oldest first.
+func (c *client) comments(key string) ([]Comment, error) {
+	return c.api.Comments(c.context(), key)
+}
+
+// updateIssue applies an edit, in the form accepted by the edit issue API,
+// to the issue identified by key.
+func (c *client) updateIssue(key string, edit interface{}) error {
+	payload, err := json.Marshal(edit)
+	if err != nil {
+		return fmt.Errorf("error encoding issue update: %w", err)
+	}
+	err = c.api.UpdateIssue(c.context(), key, edit)
+	sum := sha256.Sum256(payload)
+	if aerr := c.audit.record("update", key, "", hex.EncodeToString(sum[:]), err); aerr != nil && err == nil {
+		return fmt.Errorf("issue updated but %v", aerr)
+	}
+	return err
+}
+
+// deleteAttachment removes the attachment from the issue identified by key.
+func (c *client) deleteAttachment(key string, a Attachment) error {
+	err := c.api.DeleteAttachment(c.context(), a.ID)
+	if aerr := c.audit.record("delete", key, a.ID+" "+a.Filename, "", err); aerr != nil && err == nil {
+		return fmt.Errorf("attachment deleted but %v", aerr)
+	}
+	return err
+}
+
+// download writes the content of the attachment to w.
+func (c *client) download(a Attachment, w io.Writer) error {
+	return c.api.Download(c.context(), a, w)
+}
+
+// statusError is returned when Jira responds with a non-2xx status code.
+type statusError = jira.StatusError
+
+// sendError is returned when Jira can't be reached.
+type sendError = jira.SendError
+
+// headerNamePattern matches valid HTTP header names.
+var headerNamePattern = regexp.MustCompile("^[!#$%&'*+.^_`|~0-9A-Za-z-]+$")
+
+// parseHeader parses a header given as "Name: value", as curl's -H takes.
+func parseHeader(h string) (name, value string, err error) {
+	i := strings.Index(h, ":")
+	if i < 0 {
+		return "", "", fmt.Errorf("invalid header %q, expected \"Name: value\"", h)
+	}
+	name, value = strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:])
+	if !headerNamePattern.MatchString(name) {
+		return "", "", fmt.Errorf("invalid header name %q", name)
+	}
+	return name, value, nil
+}
+
+// doerFunc adapts a function to the jira.Doer interface.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
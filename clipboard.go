@@ -0,0 +1,133 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const pasteUsage = `usage: jiraattach paste [-name=filename] [-no-embed] key
+
+  Attach the image on the clipboard as a PNG named after the current time,
+  and post a comment showing it unless -no-embed is given. Reads the
+  clipboard with osascript on macOS, PowerShell on Windows, and wl-paste or
+  xclip on Linux.
+`
+
+// runPaste implements the paste command, attaching the image on the
+// clipboard.
+func runPaste(config *Config, args []string) error {
+	fs := newFlagSet("paste", pasteUsage)
+	name := fs.String("name", "", "filename to attach the image as, clipboard-<time>.png by default")
+	noembed := fs.Bool("no-embed", false, "don't post a comment showing the image")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+	if *name == "" {
+		*name = fmt.Sprintf("clipboard-%v.png", time.Now().UTC().Format("20060102T150405Z"))
+	} else if err := checkName(*name); err != nil {
+		return usageErrorf("invalid -name: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "jiraattach-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "clipboard.png")
+	if err := saveClipboardImage(path); err != nil {
+		return err
+	}
+	return attachImage(config, key, path, *name, !*noembed)
+}
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// saveClipboardImage writes the image on the clipboard to path as a PNG,
+// using osascript on macOS, PowerShell on Windows and wl-paste or xclip
+// elsewhere.
+func saveClipboardImage(path string) error {
+	var (
+		cmd    *exec.Cmd
+		stdout bool
+	)
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript",
+			"-e", "set png to (the clipboard as «class PNGf»)",
+			"-e", fmt.Sprintf("set f to open for access POSIX file %q with write permission", path),
+			"-e", "write png to f",
+			"-e", "close access f")
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-STA", "-Command", fmt.Sprintf(
+			"Add-Type -AssemblyName System.Windows.Forms; "+
+				"$img = [System.Windows.Forms.Clipboard]::GetImage(); "+
+				"if ($img -eq $null) { exit 1 }; "+
+				"$img.Save('%v', [System.Drawing.Imaging.ImageFormat]::Png)",
+			strings.Replace(path, "'", "''", -1)))
+	default:
+		stdout = true
+		if _, err := exec.LookPath("wl-paste"); err == nil && os.Getenv("WAYLAND_DISPLAY") != "" {
+			cmd = exec.Command("wl-paste", "--no-newline", "--type", "image/png")
+		} else if _, err := exec.LookPath("xclip"); err == nil {
+			cmd = exec.Command("xclip", "-selection", "clipboard", "-target", "image/png", "-out")
+		} else {
+			return fmt.Errorf("reading the clipboard needs wl-paste (wl-clipboard) on Wayland or xclip on X11")
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if stdout {
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error saving clipboard image: %w", err)
+		}
+		defer file.Close()
+		cmd.Stdout = file
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("no image on the clipboard: %v", msg)
+		}
+		return fmt.Errorf("no image on the clipboard: %w", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil || !bytes.HasPrefix(data, pngSignature) {
+		return fmt.Errorf("no image on the clipboard")
+	}
+	return nil
+}
+
+// attachImage attaches the image at path to the issue as name, printing its
+// content URL, and with embed posts a comment showing it.
+func attachImage(config *Config, key, path, name string, embed bool) error {
+	attachments, err := attachPath(config, key, path, name)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		fmt.Println(a.Content)
+	}
+	if images := embedImages(attachments); embed && images != "" {
+		if _, err := config.client().comment(key, images, commentOptions{}); err != nil {
+			return fmt.Errorf("error commenting on %v: %w", key, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+// Command jiraattach attaches files to Jira issues. It parses the global
+// flags and leaves the rest to the jiraattach package.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/bboughton/jiraattach"
+)
+
+// version identifies the build in the User-Agent header. Releases set it
+// with -ldflags "-X main.version=1.2.3".
+var version = "dev"
+
+func main() {
+	var opts jiraattach.Options
+	var resolve, headers jiraattach.StringList
+	defaultconfig, configset := jiraattach.DefaultConfigPath()
+	flag.StringVar(&opts.ConfigPath, "config", defaultconfig, "path to config file")
+	flag.StringVar(&opts.Profile, "profile", os.Getenv("JIRAATTACH_PROFILE"), "name of the profile to use for every issue instead of following routes")
+	flag.StringVar(&opts.Proxy, "proxy", "", "proxy to connect to Jira through, such as http://proxy:3128 or socks5://localhost:1080")
+	flag.Var(&headers, "header", "extra header to send with every request, as \"Name: value\"; may be repeated")
+	flag.StringVar(&opts.CACert, "cacert", "", "PEM bundle of CA certificates to trust along with the system's")
+	flag.StringVar(&opts.Cert, "cert", "", "PEM client certificate to present to Jira")
+	flag.StringVar(&opts.Key, "key", "", "PEM key of the client certificate, when it isn't in the -cert file")
+	flag.StringVar(&opts.TLSMinVersion, "tls-min-version", "", "oldest TLS version to accept, 1.0 to 1.3")
+	flag.BoolVar(&opts.Insecure, "insecure", false, "don't verify Jira's TLS certificate")
+	flag.Var(&resolve, "resolve", "connect to host:port at address instead of resolving it, as host:port:address")
+	flag.BoolVar(&opts.AllowInsecureHTTP, "allow-insecure-http", false, "allow credentials to be sent to Jira over plain http")
+	flag.IntVar(&opts.Retries, "retries", -1, "number of times to retry requests that fail with network errors, 429 or 5xx")
+	flag.DurationVar(&opts.RetryMaxWait, "retry-max-wait", 0, "longest wait between retries, such as 30s")
+	flag.StringVar(&opts.LimitRate, "limit-rate", "", "cap the bandwidth of all uploads together, such as 2MiB/s")
+	flag.BoolVar(&opts.Debug, "debug", false, "log every request and response on stderr")
+	flag.BoolVar(&opts.Debug, "v", false, "same as -debug")
+	flag.BoolVar(&opts.Trace, "trace", false, "log request and response bodies too, implies -debug")
+	flag.DurationVar(&opts.Timeout, "timeout", 0, "give up on the command after this long, such as 10m")
+	flag.Usage = jiraattach.Usage
+	flag.Parse()
+	flag.Visit(func(f *flag.Flag) {
+		configset = configset || f.Name == "config"
+	})
+	opts.ConfigSet = configset
+	opts.Resolve = resolve
+	opts.Headers = headers
+
+	jiraattach.Version = version
+	os.Exit(jiraattach.Main(opts, flag.Args()))
+}
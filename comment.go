@@ -0,0 +1,97 @@
+package jiraattach
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+const commentUsage = `usage: jiraattach comment [-visible-to-role=role|-visible-to-group=group]
+  [-internal] key text...
+
+  Add a comment to a Jira Issue, only shown to members of the project role
+  or group when one is given. With -internal it is an internal note on a
+  Jira Service Management issue, hidden from the customer portal.
+`
+
+// runComment implements the comment command, adding a comment to an issue.
+func runComment(config *Config, args []string) error {
+	fs := newFlagSet("comment", commentUsage)
+	commentflags := addCommentFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return usageErrorf("key and text are required")
+	}
+	opts, err := commentflags.options()
+	if err != nil {
+		return err
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	text := strings.Join(fs.Args()[1:], " ")
+	config = config.route(key)
+
+	if _, err := config.client().comment(key, text, opts); err != nil {
+		return fmt.Errorf("error commenting on %v: %w", key, err)
+	}
+	return nil
+}
+
+// commentOptions are settings for a comment beyond its body.
+type commentOptions struct {
+	// Visibility restricts the comment to members of a project role or
+	// group. Nil comments are visible to everyone who can see the issue.
+	Visibility *commentVisibility
+	// Internal keeps the comment off the Jira Service Management customer
+	// portal.
+	Internal bool
+}
+
+// commentVisibility is the visibility field of a comment.
+type commentVisibility = jira.Visibility
+
+// jira returns the options as the jira package takes them.
+func (o commentOptions) jira() *jira.CommentOptions {
+	opts := &jira.CommentOptions{Visibility: o.Visibility}
+	if o.Internal {
+		opts.Properties = []jira.EntityProperty{
+			{Key: "sd.public.comment", Value: map[string]bool{"internal": true}},
+		}
+	}
+	return opts
+}
+
+// commentFlags are the flags of commands that post comments.
+type commentFlags struct {
+	role     *string
+	group    *string
+	internal *bool
+}
+
+func addCommentFlags(fs *flag.FlagSet) *commentFlags {
+	return &commentFlags{
+		role:     fs.String("visible-to-role", "", "only show comments to members of this project role, such as Developers"),
+		group:    fs.String("visible-to-group", "", "only show comments to members of this group"),
+		internal: fs.Bool("internal", false, "post comments as internal notes on Jira Service Management issues, hidden from the customer portal"),
+	}
+}
+
+// options returns the comment options the flags were set to.
+func (f *commentFlags) options() (commentOptions, error) {
+	opts := commentOptions{Internal: *f.internal}
+	switch {
+	case *f.role != "" && *f.group != "":
+		return opts, usageErrorf("-visible-to-role and -visible-to-group can't be used together")
+	case *f.role != "":
+		opts.Visibility = &commentVisibility{Type: "role", Value: *f.role}
+	case *f.group != "":
+		opts.Visibility = &commentVisibility{Type: "group", Value: *f.group}
+	}
+	return opts, nil
+}
@@ -0,0 +1,148 @@
+package jiraattach
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const bashCompletion = `# jiraattach completion for bash and zsh. Load it with
+#   source <(jiraattach completion)
+# and with autoload -U bashcompinit && bashcompinit first in zsh.
+_jiraattach() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	COMPREPLY=()
+	case $cur in
+	[A-Z]*)
+		COMPREPLY=($(jiraattach __complete keys "$cur" 2>/dev/null))
+		;;
+	*)
+		if [ "$COMP_CWORD" -eq 1 ]; then
+			COMPREPLY=($(compgen -W "$(jiraattach __complete commands 2>/dev/null)" -- "$cur"))
+		fi
+		;;
+	esac
+}
+complete -o default -F _jiraattach jiraattach
+`
+
+// completionKeysFile is the state file caching the issue keys returned by
+// completion_jql, so that pressing tab doesn't query Jira every time.
+const completionKeysFile = "completion.json"
+
+// completionKeysTTL is how long the cached completion_jql results are used.
+const completionKeysTTL = 5 * time.Minute
+
+const completionUsage = `usage: jiraattach completion
+
+  Print a bash and zsh completion script that completes commands, and issue
+  keys from the upload history and completion_jql.
+`
+
+// runCompletion implements the completion command, printing a completion
+// script for bash and zsh.
+func runCompletion(config *Config, args []string) error {
+	fs := newFlagSet("completion", completionUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	fmt.Print(bashCompletion)
+	return nil
+}
+
+// runComplete implements the hidden __complete command that the completion
+// script calls back into. It prints the command names, or the issue keys
+// starting with a prefix, one per line.
+func runComplete(config *Config, args []string) error {
+	if len(args) < 1 {
+		return errUsage
+	}
+	var candidates []string
+	switch args[0] {
+	case "commands":
+		candidates = commandNames(config)
+	case "keys":
+		prefix := ""
+		if len(args) > 1 {
+			prefix = args[1]
+		}
+		for _, key := range completionKeys(config) {
+			if strings.HasPrefix(key, prefix) {
+				candidates = append(candidates, key)
+			}
+		}
+	default:
+		return errUsage
+	}
+	for _, c := range candidates {
+		fmt.Println(c)
+	}
+	return nil
+}
+
+// commandNames returns the names of the commands and aliases users can run,
+// sorted.
+func commandNames(config *Config) []string {
+	var names []string
+	for name := range commands {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	for name := range config.Alias {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionKeys returns the issue keys worth suggesting: those uploaded to
+// from this machine, most recent first, followed by the results of the
+// completion_jql query.
+func completionKeys(config *Config) []string {
+	seen := map[string]bool{}
+	var keys []string
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if recent, err := recentIssues(); err == nil {
+		for _, e := range recent {
+			add(e.Issue)
+		}
+	}
+	if config.CompletionJQL != "" {
+		for _, key := range jqlKeys(config) {
+			add(key)
+		}
+	}
+	return keys
+}
+
+// jqlKeys returns the keys of the issues matching completion_jql, cached for
+// completionKeysTTL. Errors are ignored since completion must never get in
+// the way of typing.
+func jqlKeys(config *Config) []string {
+	var cache struct {
+		JQL     string    `json:"jql"`
+		Fetched time.Time `json:"fetched"`
+		Keys    []string  `json:"keys"`
+	}
+	if err := loadState(completionKeysFile, &cache); err == nil && cache.JQL == config.CompletionJQL && time.Since(cache.Fetched) < completionKeysTTL {
+		return cache.Keys
+	}
+	issues, err := config.client().search(config.CompletionJQL)
+	if err != nil {
+		warnf("unable to run completion_jql: %v", err)
+		return cache.Keys
+	}
+	cache.JQL, cache.Fetched, cache.Keys = config.CompletionJQL, time.Now(), nil
+	for _, issue := range issues {
+		cache.Keys = append(cache.Keys, issue.Key)
+	}
+	saveState(completionKeysFile, &cache)
+	return cache.Keys
+}
@@ -0,0 +1,130 @@
+package jiraattach
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// compressExtensions maps the formats attachments can be compressed in to
+// the extension appended to their names.
+var compressExtensions = map[string]string{
+	"gzip": ".gz",
+	"zstd": ".zst",
+}
+
+// compressFormats returns the compression formats in order.
+func compressFormats() []string {
+	var formats []string
+	for format := range compressExtensions {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// compressFlag is the -compress flag, which may be given alone for gzip or
+// as -compress=gzip or -compress=zstd.
+type compressFlag struct {
+	format *string
+}
+
+func (f compressFlag) String() string {
+	if f.format == nil {
+		return ""
+	}
+	return *f.format
+}
+
+func (f compressFlag) Set(s string) error {
+	switch s {
+	case "true":
+		s = "gzip"
+	case "false":
+		s = ""
+	}
+	*f.format = s
+	return nil
+}
+
+func (f compressFlag) IsBoolFlag() bool {
+	return true
+}
+
+// sniffSize is how much of an attachment is read to decide whether it is
+// text.
+const sniffSize = 8 << 10
+
+// compression returns the format to compress the attachment read from r in,
+// or "" to upload it as is. Everything is compressed when compress is set;
+// otherwise text files larger than auto_compress_threshold are compressed
+// with gzip. Peeking at r to tell whether it is text leaves it where it was.
+func (c *Config) compression(r io.Reader) (string, error) {
+	if c.Compress != "" || c.AutoCompressThreshold == "" {
+		return c.Compress, nil
+	}
+	threshold, err := parseSize(c.AutoCompressThreshold)
+	if err != nil {
+		return "", fmt.Errorf("invalid auto_compress_threshold: %w", err)
+	}
+	size := remaining(r)
+	if size <= threshold {
+		// Streams of unknown size are never compressed automatically.
+		return "", nil
+	}
+	head, _, err := peek(r, sniffSize)
+	if err != nil {
+		return "", err
+	}
+	if !isText(head) {
+		return "", nil
+	}
+	return "gzip", nil
+}
+
+// isText reports whether data, the start of a file, looks like text rather
+// than binary or already compressed data.
+func isText(data []byte) bool {
+	return bytes.IndexByte(data, 0) < 0 && !bytes.HasPrefix(data, []byte{0x1f, 0x8b})
+}
+
+// compress writes r compressed in format to a temporary file, returned
+// positioned at its start along with a function removing it. zstd
+// compression runs the zstd executable found on the PATH.
+func compress(r io.Reader, format string) (*os.File, func(), error) {
+	tmp, err := ioutil.TempFile("", "jiraattach-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	switch format {
+	case "gzip":
+		gw := gzip.NewWriter(tmp)
+		if _, err = io.Copy(gw, r); err == nil {
+			err = gw.Close()
+		}
+	case "zstd":
+		cmd := exec.Command("zstd", "-q", "-c")
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = r, tmp, os.Stderr
+		err = cmd.Run()
+	default:
+		err = fmt.Errorf("invalid compression format %q, expected %v", format, strings.Join(compressFormats(), " or "))
+	}
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	return tmp, cleanup, nil
+}
@@ -0,0 +1,333 @@
+package jiraattach
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+)
+
+type Config struct {
+	JiraURL   string            `json:"jira_url"`
+	Auth      string            `json:"auth"`
+	AuthType  string            `json:"auth_type"`
+	OAuth     OAuthConfig       `json:"oauth"`
+	Alias     map[string]string `json:"alias"`
+	AuditLog  string            `json:"audit_log"`
+	Antivirus *AntivirusConfig  `json:"antivirus"`
+	Transcode TranscodeConfig   `json:"transcode"`
+	Proxy     string            `json:"proxy"`
+	ProxyAuth string            `json:"proxy_auth"`
+	UserAgent string            `json:"user_agent"`
+	Headers   map[string]string `json:"headers"`
+	Resolve   []string          `json:"resolve"`
+	Dial      string            `json:"dial"`
+
+	Compress              string `json:"compress"`
+	AutoCompressThreshold string `json:"auto_compress_threshold"`
+	Split                 bool   `json:"split"`
+	Concurrency           int    `json:"concurrency"`
+	LimitRate             string `json:"limit_rate"`
+
+	CommentTemplate string `json:"comment_template"`
+	CommentFormat   string `json:"comment_format"`
+
+	Profiles map[string]Profile `json:"profiles"`
+	Routes   map[string]string  `json:"routes"`
+
+	IssueBudget     string        `json:"issue_budget"`
+	CompletionJQL   string        `json:"completion_jql"`
+	CapabilitiesTTL string        `json:"capabilities_ttl"`
+	Retry           RetryConfig   `json:"retry"`
+	Timeouts        TimeoutConfig `json:"timeouts"`
+	TLS             TLSConfig     `json:"tls"`
+
+	AllowInsecureHTTP bool     `json:"allow_insecure_http"`
+	AllowedSources    []string `json:"allowed_sources"`
+
+	AllowSecrets   bool              `json:"allow_secrets"`
+	SecretPatterns map[string]string `json:"secret_patterns"`
+
+	path           string
+	settings       *settings
+	contentType    string
+	limiter        *rateLimiter
+	resume         bool
+	pending        *pendingUpload
+	c              *client
+	profileClients map[string]*client
+}
+
+// client returns the Jira client for this config. The client is created on
+// first use and shared by every command run against the config, so a shell
+// session reuses its connections.
+func (c *Config) client() *client {
+	if c.c == nil {
+		c.c = newClient(c)
+	}
+	return c.c
+}
+
+// forCommand returns a copy of the config for one command of a shell
+// session, so that the command's flags don't carry over to the commands
+// that follow. The copy shares the config's clients and their connections.
+func (c *Config) forCommand() *Config {
+	c.client()
+	if c.profileClients == nil {
+		c.profileClients = map[string]*client{}
+	}
+	copied := *c
+	return &copied
+}
+
+// loadConfig reads the JSON config file at path.
+func loadConfig(path string) (*Config, error) {
+	configfile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open config file, %v", path)
+	}
+	defer configfile.Close()
+	config := &Config{path: path}
+	if err := json.NewDecoder(configfile).Decode(config); err != nil {
+		return nil, fmt.Errorf("failed to read config file, %v: %w", path, err)
+	}
+	return config, nil
+}
+
+// envPrefix starts the name of every environment variable that overrides a
+// setting, such as JIRAATTACH_AUTH for auth.
+const envPrefix = "JIRAATTACH_"
+
+// envAliases are shorter names accepted for some settings' environment
+// variables.
+var envAliases = map[string][]string{
+	"jira_url": {"URL"},
+}
+
+// applyEnv overrides settings with any JIRAATTACH_ environment variables,
+// named after the settings in upper case. String settings take the value as
+// is and every other setting takes it as JSON, so CI pipelines can supply
+// everything, secrets included, without writing a config file.
+func (c *Config) applyEnv() error {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		setting := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || setting == "" || setting == "-" {
+			continue
+		}
+		var (
+			name, value string
+			ok          bool
+		)
+		for _, suffix := range append([]string{strings.ToUpper(setting)}, envAliases[setting]...) {
+			name = envPrefix + suffix
+			if value, ok = os.LookupEnv(name); ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		if field.Type.Kind() == reflect.String {
+			v.Field(i).SetString(value)
+			continue
+		}
+		if err := json.Unmarshal([]byte(value), v.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid %v, expected JSON for %v: %w", name, setting, err)
+		}
+	}
+	return nil
+}
+
+// validate checks settings that can't be checked while decoding, once flags
+// have been applied to the config.
+func (c *Config) validate() error {
+	urls := []string{c.JiraURL}
+	for _, p := range c.Profiles {
+		urls = append(urls, p.JiraURL)
+	}
+	for _, u := range urls {
+		if err := checkInsecureHTTP(u, c.AllowInsecureHTTP); err != nil {
+			return err
+		}
+	}
+	if err := c.validateAuth(); err != nil {
+		return err
+	}
+	if c.Proxy != "" {
+		if _, err := parseProxyURL(c.Proxy); err != nil {
+			return err
+		}
+	}
+	if c.ProxyAuth != "" && !strings.Contains(c.ProxyAuth, ":") {
+		return fmt.Errorf("proxy_auth must be 'username:password'")
+	}
+	if _, err := parseResolve(c.Resolve); err != nil {
+		return err
+	}
+	if err := c.validateSources(); err != nil {
+		return err
+	}
+	if err := c.validateRoutes(); err != nil {
+		return err
+	}
+	headers := []map[string]string{c.Headers}
+	for _, p := range c.Profiles {
+		headers = append(headers, p.Headers)
+	}
+	for _, h := range headers {
+		for name := range h {
+			if !headerNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid header name %q", name)
+			}
+		}
+	}
+	if err := c.Retry.validate(); err != nil {
+		return err
+	}
+	if err := c.Timeouts.validate(); err != nil {
+		return err
+	}
+	if _, err := c.TLS.clientConfig(); err != nil {
+		return err
+	}
+	if c.IssueBudget != "" {
+		if _, err := parseSize(c.IssueBudget); err != nil {
+			return fmt.Errorf("invalid issue_budget: %w", err)
+		}
+	}
+	if _, ok := compressExtensions[c.Compress]; c.Compress != "" && !ok {
+		return fmt.Errorf("invalid compress %q, expected %v", c.Compress, strings.Join(compressFormats(), " or "))
+	}
+	if c.AutoCompressThreshold != "" {
+		if _, err := parseSize(c.AutoCompressThreshold); err != nil {
+			return fmt.Errorf("invalid auto_compress_threshold: %w", err)
+		}
+	}
+	if c.LimitRate != "" {
+		if _, err := parseRate(c.LimitRate); err != nil {
+			return fmt.Errorf("invalid limit_rate: %w", err)
+		}
+	}
+	if c.Concurrency < 0 {
+		return fmt.Errorf("invalid concurrency %d, expected at least 1", c.Concurrency)
+	}
+	if c.CapabilitiesTTL != "" {
+		if _, err := parseAge(c.CapabilitiesTTL); err != nil {
+			return fmt.Errorf("invalid capabilities_ttl: %w", err)
+		}
+	}
+	switch c.CommentFormat {
+	case "", commentFormatAuto, commentFormatWiki, commentFormatADF:
+	default:
+		return fmt.Errorf("invalid comment_format %q, expected auto, wiki or adf", c.CommentFormat)
+	}
+	if c.CommentTemplate != "" {
+		if _, err := parseMessage("comment_template", c.CommentTemplate); err != nil {
+			return err
+		}
+	}
+	if c.Dial != "" {
+		if _, _, err := parseDial(c.Dial); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkInsecureHTTP refuses URLs that would send credentials over plain
+// http to anywhere but the local machine, unless allow is set.
+func checkInsecureHTTP(rawurl string, allow bool) error {
+	if allow || rawurl == "" {
+		return nil
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return fmt.Errorf("invalid Jira URL %v: %w", rawurl, err)
+	}
+	if !strings.EqualFold(u.Scheme, "http") || u.Hostname() == "localhost" || isLoopback(u.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("refusing to send credentials to %v over plain http, use an https URL or pass -allow-insecure-http", u.Host)
+}
+
+// expandAlias replaces a leading alias name in args with the command line it
+// is defined as. Aliases may refer to other aliases but never shadow a
+// built-in command.
+func (c *Config) expandAlias(args []string) ([]string, error) {
+	seen := map[string]bool{}
+	for len(args) > 0 {
+		name := args[0]
+		if _, ok := commands[name]; ok {
+			return args, nil
+		}
+		line, ok := c.Alias[name]
+		if !ok {
+			return args, nil
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("alias %v is recursive", name)
+		}
+		seen[name] = true
+		expanded, err := splitArgs(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias %v: %w", name, err)
+		}
+		if len(expanded) == 0 {
+			return nil, fmt.Errorf("alias %v is empty", name)
+		}
+		args = append(expanded, args[1:]...)
+	}
+	return args, nil
+}
+
+// splitArgs splits s into arguments the way a shell would, honoring single
+// quotes, double quotes and backslash escapes.
+func splitArgs(s string) ([]string, error) {
+	var (
+		args    []string
+		arg     strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
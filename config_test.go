@@ -0,0 +1,123 @@
+package jiraattach
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// withEnv runs f with only the given JIRAATTACH_ variables set, restoring
+// the environment afterwards.
+func withEnv(t *testing.T, env map[string]string, f func()) {
+	t.Helper()
+	saved := map[string]string{}
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, envPrefix) {
+			name := strings.SplitN(kv, "=", 2)[0]
+			saved[name] = os.Getenv(name)
+			os.Unsetenv(name)
+		}
+	}
+	defer func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+		for name, value := range saved {
+			os.Setenv(name, value)
+		}
+	}()
+	for name, value := range env {
+		os.Setenv(name, value)
+	}
+	f()
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		env     map[string]string
+		want    Config
+		wantErr string
+	}{
+		{
+			name:   "no variables",
+			config: Config{JiraURL: "https://a.example.com", Auth: "me:pw"},
+			want:   Config{JiraURL: "https://a.example.com", Auth: "me:pw"},
+		},
+		{
+			name:   "strings are taken as is",
+			config: Config{JiraURL: "https://a.example.com", Auth: "me:pw"},
+			env:    map[string]string{"JIRAATTACH_AUTH": `you:"quoted"`, "JIRAATTACH_AUTH_TYPE": "api_token"},
+			want:   Config{JiraURL: "https://a.example.com", Auth: `you:"quoted"`, AuthType: "api_token"},
+		},
+		{
+			name:   "empty string clears a setting",
+			config: Config{Auth: "me:pw"},
+			env:    map[string]string{"JIRAATTACH_AUTH": ""},
+			want:   Config{},
+		},
+		{
+			name: "URL alias for jira_url",
+			env:  map[string]string{"JIRAATTACH_URL": "https://b.example.com"},
+			want: Config{JiraURL: "https://b.example.com"},
+		},
+		{
+			name: "full name wins over the alias",
+			env:  map[string]string{"JIRAATTACH_JIRA_URL": "https://c.example.com", "JIRAATTACH_URL": "https://b.example.com"},
+			want: Config{JiraURL: "https://c.example.com"},
+		},
+		{
+			name: "booleans and numbers are JSON",
+			env:  map[string]string{"JIRAATTACH_SPLIT": "true", "JIRAATTACH_CONCURRENCY": "4", "JIRAATTACH_ALLOW_INSECURE_HTTP": "true"},
+			want: Config{Split: true, Concurrency: 4, AllowInsecureHTTP: true},
+		},
+		{
+			name: "lists and maps are JSON",
+			env: map[string]string{
+				"JIRAATTACH_RESOLVE": `["jira:443:10.0.0.1"]`,
+				"JIRAATTACH_HEADERS": `{"X-Key": "secret"}`,
+			},
+			want: Config{Resolve: []string{"jira:443:10.0.0.1"}, Headers: map[string]string{"X-Key": "secret"}},
+		},
+		{
+			name:   "structs are merged with the config file",
+			config: Config{Retry: RetryConfig{RetryPolicy: RetryPolicy{MaxAttempts: 2, BaseDelay: duration{time.Second}}}},
+			env:    map[string]string{"JIRAATTACH_RETRY": `{"max_attempts": 5}`},
+			want:   Config{Retry: RetryConfig{RetryPolicy: RetryPolicy{MaxAttempts: 5, BaseDelay: duration{time.Second}}}},
+		},
+		{
+			name:    "invalid JSON",
+			env:     map[string]string{"JIRAATTACH_SPLIT": "yes"},
+			wantErr: "invalid JIRAATTACH_SPLIT, expected JSON for split",
+		},
+		{
+			name:   "unexported fields can't be set",
+			config: Config{path: "config.json"},
+			env:    map[string]string{"JIRAATTACH_PATH": "other.json", "JIRAATTACH_C": "x"},
+			want:   Config{path: "config.json"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withEnv(t, test.env, func() {
+				config := test.config
+				err := config.applyEnv()
+				if test.wantErr != "" {
+					if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+						t.Errorf("applyEnv error = %v, want %q", err, test.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("applyEnv: %v", err)
+				}
+				if !reflect.DeepEqual(config, test.want) {
+					t.Errorf("applyEnv = %+v, want %+v", config, test.want)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,244 @@
+package jiraattach
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// runConfig implements the config command, dispatching to its subcommands.
+func runConfig(config *Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("config requires a subcommand: init or validate")
+	}
+	switch args[0] {
+	case "init":
+		return runConfigInit(config, args[1:])
+	case "validate", "doctor":
+		return runConfigValidate(config, args[1:])
+	}
+	return fmt.Errorf("unknown config subcommand %q, expected init or validate", args[0])
+}
+
+// initConfig is the config file written by config init, holding only the
+// settings it asks for.
+type initConfig struct {
+	JiraURL  string `json:"jira_url"`
+	AuthType string `json:"auth_type,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+const configInitUsage = `usage: jiraattach config init
+
+  Create the config file by asking for the Jira URL and credentials,
+  checking them and writing the file readable only by you, with the
+  credentials optionally kept in the OS keyring instead.
+`
+
+// runConfigInit implements config init, asking for the Jira URL and
+// credentials, checking them and writing a config file only the user can
+// read.
+func runConfigInit(config *Config, args []string) error {
+	fs := newFlagSet("config init", configInitUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if _, err := os.Stat(config.path); err == nil {
+		if !confirm(fmt.Sprintf("%v already exists, overwrite it?", config.path), false) {
+			return nil
+		}
+	}
+
+	in := &plainReader{out: os.Stderr}
+	jiraURL, err := in.readLine("Jira URL, such as https://example.atlassian.net: ")
+	if err != nil {
+		return err
+	}
+	jiraURL = strings.TrimSuffix(strings.TrimSpace(jiraURL), "/")
+	if jiraURL == "" {
+		return usageErrorf("a Jira URL is required")
+	}
+	if err := checkInsecureHTTP(jiraURL, config.AllowInsecureHTTP); err != nil {
+		return err
+	}
+	authType := authBasic
+	if strings.HasSuffix(strings.ToLower(jiraURL), ".atlassian.net") {
+		authType = authAPIToken
+	}
+	answer, err := in.readLine(fmt.Sprintf("Authentication, basic, api_token or pat [%v]: ", authType))
+	if err != nil {
+		return err
+	}
+	if answer = strings.TrimSpace(answer); answer != "" {
+		authType = answer
+	}
+	if authType == authOAuth {
+		return fmt.Errorf("set up OAuth by hand as described in the README, then run jiraattach login")
+	}
+	if _, _, _, err := parseAuth(authType, "x:x"); err != nil {
+		return err
+	}
+
+	auth, err := promptAuth(authType)
+	if err != nil {
+		return err
+	}
+	check := *config
+	check.JiraURL, check.AuthType = jiraURL, authType
+	me, err := checkAuth(&check, auth)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Authenticated as %v\n", me.DisplayName)
+
+	written := initConfig{JiraURL: jiraURL, AuthType: authType, Auth: auth}
+	if authType == authBasic {
+		written.AuthType = ""
+	}
+	if confirm("Save the credentials in the OS keyring instead of the config file?", true) {
+		if err := keyringSet(jiraURL, auth); err != nil {
+			warnf("%v, saving them in the config file instead", err)
+		} else {
+			written.Auth = ""
+		}
+	}
+	if err := writeConfig(config.path, written); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %v\n", config.path)
+	return nil
+}
+
+// writeConfig writes v as the JSON config file at path, readable only by
+// the user since it may hold credentials.
+func writeConfig(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error writing config file, %v: %w", path, err)
+	}
+	defer f.Close()
+	// An existing file keeps its mode when truncated.
+	if err := f.Chmod(0600); err != nil {
+		return fmt.Errorf("error writing config file, %v: %w", path, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing config file, %v: %w", path, err)
+	}
+	return f.Close()
+}
+
+const configValidateUsage = `usage: jiraattach config validate [-project=key]
+
+  Check that the config file loads, that Jira is reachable, that the
+  credentials authenticate and that attachments are enabled, and with
+  -project that the account may attach files in the project, explaining how
+  to fix each failure. Also available as config doctor.
+`
+
+// runConfigValidate implements config validate, checking that the config
+// loads, that Jira is reachable, that the credentials authenticate and,
+// given a project, that they may attach files there. Each failure is
+// reported with what to do about it.
+func runConfigValidate(config *Config, args []string) error {
+	fs := newFlagSet("config validate", configValidateUsage)
+	project := fs.String("project", "", "project key to check attachment permissions on")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	failed := 0
+	check := func(name string, err error, hint string) bool {
+		if err == nil {
+			fmt.Printf("ok    %v\n", name)
+			return true
+		}
+		failed++
+		fmt.Printf("FAIL  %v: %v\n", name, redact(err.Error()))
+		if hint != "" {
+			fmt.Printf("      %v\n", hint)
+		}
+		return false
+	}
+	done := func() error {
+		if failed == 1 {
+			return fmt.Errorf("1 check failed")
+		}
+		if failed > 1 {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		return nil
+	}
+
+	loaded, err := config.settings.load()
+	if !check("config "+config.path, err, "run jiraattach config init to create a config file, or fix the setting named above") {
+		return done()
+	}
+	if *project != "" {
+		loaded = loaded.route(*project + "-")
+	}
+	c := loaded.client()
+
+	var info struct {
+		Version string `json:"version"`
+	}
+	req, err := c.newRequest("GET", "/rest/api/2/serverInfo", nil)
+	if err == nil {
+		err = c.do(req, &info)
+	}
+	hint := "check jira_url and any proxy, resolve or dial settings, and that this machine can reach the host"
+	if _, ok := err.(*statusError); ok {
+		hint = "jira_url should be the base URL of Jira, such as https://example.atlassian.net"
+	}
+	if !check("reach "+loaded.JiraURL, err, hint) {
+		return done()
+	}
+
+	me, err := c.myself()
+	hint = ""
+	switch e := err.(type) {
+	case nil:
+	case *statusError:
+		switch {
+		case e.StatusCode == http.StatusUnauthorized && loaded.AuthType == authOAuth:
+			hint = "the login is no longer valid, run jiraattach login"
+		case e.StatusCode == http.StatusUnauthorized:
+			hint = "check auth and auth_type; Jira Cloud needs auth_type api_token with an email and API token, and tokens can expire or be revoked"
+		case e.StatusCode == http.StatusForbidden:
+			hint = "the account may be locked behind a CAPTCHA after failed logins, log in to Jira in a browser to clear it"
+		}
+	default:
+		hint = "run jiraattach login, or set auth in the config file"
+	}
+	name := "authenticate"
+	if me != nil {
+		name += " as " + me.DisplayName
+	}
+	if !check(name, err, hint) {
+		return done()
+	}
+
+	var meta struct {
+		Enabled bool `json:"enabled"`
+	}
+	if req, err = c.newRequest("GET", "/rest/api/2/attachment/meta", nil); err == nil {
+		if err = c.do(req, &meta); err == nil && !meta.Enabled {
+			err = fmt.Errorf("attachments are disabled")
+		}
+	}
+	check("attachments enabled", err, "a Jira administrator has to enable attachments")
+
+	if *project != "" {
+		check("attach in "+*project, c.canAttach(*project), "ask a project administrator for the Create Attachments permission")
+	}
+	return done()
+}
@@ -0,0 +1,46 @@
+package jiraattach
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+// sniffLen is how much of a file http.DetectContentType looks at.
+const sniffLen = 512
+
+// detectContentType returns the MIME type of the file named filename that
+// starts with head: from its extension when that is known, otherwise by
+// sniffing its content.
+func detectContentType(filename string, head []byte) string {
+	if t := jira.ContentTypeByName(filename); t != "application/octet-stream" {
+		return t
+	}
+	return http.DetectContentType(head)
+}
+
+// peek returns up to the first n bytes r has left without consuming them,
+// along with the reader to read from in place of r. Readers that can seek
+// are returned to where they were; others are wrapped in a buffer.
+func peek(r io.Reader, n int) ([]byte, io.Reader, error) {
+	if rs, ok := r.(io.ReadSeeker); ok {
+		head := make([]byte, n)
+		read, err := io.ReadFull(rs, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, nil, fmt.Errorf("error reading attachment: %w", err)
+		}
+		if _, err := rs.Seek(int64(-read), io.SeekCurrent); err != nil {
+			return nil, nil, fmt.Errorf("error rewinding attachment: %w", err)
+		}
+		return head[:read], r, nil
+	}
+	br := bufio.NewReaderSize(r, n)
+	head, err := br.Peek(n)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, nil, fmt.Errorf("error reading attachment: %w", err)
+	}
+	return head, br, nil
+}
@@ -0,0 +1,152 @@
+package jiraattach
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// traceLimit is how much of each request and response body -trace shows.
+const traceLimit = 64 << 10
+
+// debugTransport logs every request and response on stderr for -debug,
+// along with their bodies for -trace. Everything logged goes through
+// redact.
+type debugTransport struct {
+	next   http.RoundTripper
+	bodies bool
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var sent *prefixWriter
+	if t.bodies && req.Body != nil && req.Body != http.NoBody {
+		// The body is captured as the transport sends it, since reading it
+		// ahead of time would consume an upload that can't be replayed.
+		sent = &prefixWriter{}
+		clone := *req
+		clone.Body = &teeBody{Reader: io.TeeReader(req.Body, sent), Closer: req.Body}
+		req = &clone
+	}
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %v %v\n", req.Method, req.URL)
+	writeHeaders(&b, "> ", req.Header)
+	if sent != nil {
+		writeBody(&b, "> ", sent.bytes(), sent.total())
+	}
+	if err != nil {
+		fmt.Fprintf(&b, "< error after %v: %v", elapsed, err)
+		fmt.Fprintln(os.Stderr, redact(b.String()))
+		return nil, err
+	}
+	fmt.Fprintf(&b, "< %v %v\n", resp.Status, elapsed)
+	writeHeaders(&b, "< ", resp.Header)
+	if t.bodies && resp.Body != nil {
+		prefix, rerr := ioutil.ReadAll(io.LimitReader(resp.Body, traceLimit))
+		length := int64(len(prefix))
+		if rerr == nil && length == traceLimit {
+			length = -1
+		}
+		shown := prefix
+		if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+			// Show what the gzip encoded body decompresses to, as far as
+			// the part read goes.
+			if zr, err := gzip.NewReader(bytes.NewReader(prefix)); err == nil {
+				shown, _ = ioutil.ReadAll(zr)
+				length = -1
+				if rerr == nil && int64(len(prefix)) < traceLimit {
+					length = int64(len(shown))
+				}
+			}
+		}
+		writeBody(&b, "< ", shown, length)
+		resp.Body = &teeBody{Reader: io.MultiReader(bytes.NewReader(prefix), resp.Body), Closer: resp.Body}
+	}
+	fmt.Fprint(os.Stderr, redact(b.String()))
+	return resp, nil
+}
+
+// writeHeaders writes headers in sorted order, each line starting with
+// prefix.
+func writeHeaders(b *strings.Builder, prefix string, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			fmt.Fprintf(b, "%v%v: %v\n", prefix, name, v)
+		}
+	}
+}
+
+// writeBody writes the start of a body of length bytes, -1 when unknown,
+// noting how much was left out. Binary content is summarized rather than
+// written out.
+func writeBody(b *strings.Builder, prefix string, body []byte, length int64) {
+	if len(body) == 0 {
+		return
+	}
+	if bytes.IndexByte(body, 0) >= 0 {
+		fmt.Fprintf(b, "%v[binary body, %d bytes shown]\n", prefix, len(body))
+		return
+	}
+	for _, line := range strings.Split(strings.TrimRight(string(body), "\n"), "\n") {
+		fmt.Fprintf(b, "%v%v\n", prefix, strings.TrimRight(line, "\r"))
+	}
+	if length < 0 || int64(len(body)) < length {
+		fmt.Fprintf(b, "%v[body truncated after %d bytes]\n", prefix, len(body))
+	}
+}
+
+// prefixWriter keeps the first traceLimit bytes written to it and counts
+// the rest.
+type prefixWriter struct {
+	mu  sync.Mutex
+	buf []byte
+	n   int64
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if room := traceLimit - len(w.buf); room > 0 {
+		if room > len(p) {
+			room = len(p)
+		}
+		w.buf = append(w.buf, p[:room]...)
+	}
+	w.n += int64(len(p))
+	return len(p), nil
+}
+
+func (w *prefixWriter) bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte(nil), w.buf...)
+}
+
+func (w *prefixWriter) total() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.n
+}
+
+// teeBody is a request or response body read through a different reader
+// than the one closed.
+type teeBody struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,92 @@
+package jiraattach
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+const dedupeUsage = `usage: jiraattach dedupe [-hash] [-keep=oldest|newest] [-dry-run] key
+
+  Delete duplicate attachments from a Jira Issue. Attachments are duplicates
+  when they share a name and size, or with -hash when their content is
+  identical.
+`
+
+// runDedupe implements the dedupe command, removing duplicate attachments
+// from an issue.
+func runDedupe(config *Config, args []string) error {
+	fs := newFlagSet("dedupe", dedupeUsage)
+	byhash := fs.Bool("hash", false, "compare attachment content instead of name and size")
+	keep := fs.String("keep", "oldest", "which duplicate to keep, oldest or newest")
+	dryrun := fs.Bool("dry-run", false, "report duplicates without deleting them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+	if *keep != "oldest" && *keep != "newest" {
+		return fmt.Errorf("invalid keep policy, %v: must be oldest or newest", *keep)
+	}
+
+	c := config.client()
+	attachments, err := c.attachments(key)
+	if err != nil {
+		return fmt.Errorf("error listing attachments on %v: %w", key, err)
+	}
+	sortAttachments(attachments)
+	if *keep == "newest" {
+		for i, j := 0, len(attachments)-1; i < j; i, j = i+1, j-1 {
+			attachments[i], attachments[j] = attachments[j], attachments[i]
+		}
+	}
+
+	kept := map[string]Attachment{}
+	for _, a := range attachments {
+		id := fmt.Sprintf("%v\x00%d", a.Filename, a.Size)
+		if *byhash {
+			id, err = hashAttachment(c, a)
+			if err != nil {
+				return err
+			}
+		}
+		original, ok := kept[id]
+		if !ok {
+			kept[id] = a
+			continue
+		}
+		if *dryrun {
+			fmt.Printf("would delete %v %v, duplicate of %v\n", a.ID, a.Filename, original.ID)
+			continue
+		}
+		if err := c.deleteAttachment(key, a); err != nil {
+			return fmt.Errorf("error deleting attachment %v: %w", a.ID, err)
+		}
+		fmt.Printf("deleted %v %v, duplicate of %v\n", a.ID, a.Filename, original.ID)
+	}
+	return nil
+}
+
+// sortAttachments orders attachments from oldest to newest.
+func sortAttachments(attachments []Attachment) {
+	sort.SliceStable(attachments, func(i, j int) bool {
+		return attachments[i].Created.Before(attachments[j].Created.Time)
+	})
+}
+
+// hashAttachment downloads the attachment and returns the hex encoded
+// SHA-256 of its content.
+func hashAttachment(c *client, a Attachment) (string, error) {
+	h := sha256.New()
+	if err := c.download(a, h); err != nil {
+		return "", fmt.Errorf("error downloading %v: %w", a.Filename, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,89 @@
+package jiraattach
+
+import (
+	"fmt"
+)
+
+// identifiedAttachment pairs an attachment with the identity it is compared
+// by.
+type identifiedAttachment struct {
+	Attachment
+	identity string
+}
+
+const diffUsage = `usage: jiraattach diff [-hash] key-a key-b
+
+  List attachments present on one Jira Issue but not the other, comparing by
+  name and size or with -hash by content. Lines starting with < are only on
+  key-a and lines starting with > only on key-b.
+`
+
+// runDiff implements the diff command, listing attachments present on one
+// issue but not the other.
+func runDiff(config *Config, args []string) error {
+	fs := newFlagSet("diff", diffUsage)
+	byhash := fs.Bool("hash", false, "compare attachment content instead of name and size")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return usageErrorf("two keys are required")
+	}
+	keyA, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	keyB, err := config.issueKey(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	identify := func(key string) ([]identifiedAttachment, error) {
+		c := config.route(key).client()
+		attachments, err := c.attachments(key)
+		if err != nil {
+			return nil, fmt.Errorf("error listing attachments on %v: %w", key, err)
+		}
+		sortAttachments(attachments)
+		identified := make([]identifiedAttachment, len(attachments))
+		for i, a := range attachments {
+			identified[i].Attachment = a
+			identified[i].identity = fmt.Sprintf("%v\x00%d", a.Filename, a.Size)
+			if *byhash {
+				if identified[i].identity, err = hashAttachment(c, a); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return identified, nil
+	}
+
+	a, err := identify(keyA)
+	if err != nil {
+		return err
+	}
+	b, err := identify(keyB)
+	if err != nil {
+		return err
+	}
+	reportUnmatched("<", keyA, a, b)
+	reportUnmatched(">", keyB, b, a)
+	return nil
+}
+
+// reportUnmatched prints the attachments that have no counterpart in other.
+// Attachments are compared as multisets so that an issue with two copies of
+// a file differs from an issue with one.
+func reportUnmatched(marker, key string, attachments, other []identifiedAttachment) {
+	counts := map[string]int{}
+	for _, a := range other {
+		counts[a.identity]++
+	}
+	for _, a := range attachments {
+		if counts[a.identity] > 0 {
+			counts[a.identity]--
+			continue
+		}
+		fmt.Printf("%v %v\t%v\t%v\t%v\n", marker, key, a.ID, a.Size, a.Filename)
+	}
+}
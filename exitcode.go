@@ -0,0 +1,90 @@
+package jiraattach
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Exit codes, documented in the usage text so scripts can tell failures
+// apart.
+const (
+	exitFailure  = 1 // any other failure
+	exitUsage    = 2 // invalid arguments or config
+	exitAuth     = 3 // authentication failed or a permission is missing
+	exitNotFound = 4 // the issue doesn't exist or can't be seen
+	exitTooLarge = 5 // a file is larger than Jira accepts
+	exitNetwork  = 6 // Jira couldn't be reached
+	exitPartial  = 7 // some files or issues failed while others succeeded
+	exitTimeout  = 8 // the -timeout deadline passed
+	exitCommand  = 9 // the -exec command failed
+
+	exitInterrupted = 130 // stopped by Ctrl-C or SIGTERM
+)
+
+// exitError is an error that knows which exit code it should cause.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// usageErrorf returns an error for arguments that don't make sense
+// together, exiting with exitUsage.
+func usageErrorf(format string, args ...interface{}) error {
+	return &exitError{code: exitUsage, err: fmt.Errorf(format, args...)}
+}
+
+// exitCode returns the exit code for err, decided by the errors it wraps:
+// an exitError's own code, the status of a failed response, or a network
+// failure.
+func exitCode(err error) int {
+	var (
+		exit   *exitError
+		status *statusError
+		send   *sendError
+	)
+	switch {
+	case errors.As(err, &exit):
+		return exit.code
+	case errors.As(err, &status):
+		return statusExitCode(status.StatusCode)
+	case errors.As(err, &send):
+		return exitNetwork
+	}
+	return exitFailure
+}
+
+// statusExitCode returns the exit code for a failed response's status.
+func statusExitCode(status int) int {
+	switch status {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return exitAuth
+	case http.StatusNotFound:
+		return exitNotFound
+	case http.StatusRequestEntityTooLarge:
+		return exitTooLarge
+	}
+	return exitFailure
+}
+
+// partialError gives err, the failure of a run where failed of total files
+// or issues failed, exitPartial when some succeeded. When all of them
+// failed the exit code is that of first, the first failure.
+func partialError(err error, failed, total int, first error) error {
+	code := exitFailure
+	switch {
+	case failed < total:
+		code = exitPartial
+	case first != nil:
+		code = exitCode(first)
+	}
+	return &exitError{code: code, err: err}
+}
@@ -0,0 +1,79 @@
+package jiraattach
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "plain error", err: errors.New("something broke"), want: exitFailure},
+		{name: "exit error", err: &exitError{code: exitTooLarge, err: errors.New("too big")}, want: exitTooLarge},
+		{name: "usage error", err: usageErrorf("bad flag"), want: exitUsage},
+		{name: "wrapped exit error", err: fmt.Errorf("error attaching f.txt: %w", usageErrorf("bad name")), want: exitUsage},
+		{name: "unauthorized", err: &statusError{StatusCode: 401}, want: exitAuth},
+		{name: "forbidden", err: &statusError{StatusCode: 403}, want: exitAuth},
+		{name: "not found", err: &statusError{StatusCode: 404}, want: exitNotFound},
+		{name: "too large", err: &statusError{StatusCode: 413}, want: exitTooLarge},
+		{name: "server error", err: &statusError{StatusCode: 500}, want: exitFailure},
+		{name: "rate limited", err: &statusError{StatusCode: 429}, want: exitFailure},
+		{
+			name: "wrapped status",
+			err:  fmt.Errorf("error listing attachments on PROJ-1: %w", &statusError{StatusCode: 404, Body: "{}"}),
+			want: exitNotFound,
+		},
+		{
+			name: "twice wrapped status",
+			err:  fmt.Errorf("error attaching f.txt: %w", fmt.Errorf("error uploading: %w", &statusError{StatusCode: 403})),
+			want: exitAuth,
+		},
+		{name: "send error", err: &sendError{Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, want: exitNetwork},
+		{name: "wrapped send error", err: fmt.Errorf("error commenting on PROJ-1: %w", &sendError{Err: errors.New("EOF")}), want: exitNetwork},
+		{
+			name: "exit error wins over what it wraps",
+			err:  &exitError{code: exitTimeout, err: fmt.Errorf("timed out: %w", &sendError{Err: errors.New("canceled")})},
+			want: exitTimeout,
+		},
+		{
+			name: "status text without a status error",
+			err:  errors.New("request failed with status code, 404"),
+			want: exitFailure,
+		},
+		{name: "send text without a send error", err: errors.New("error sending request: EOF"), want: exitFailure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := exitCode(test.err); got != test.want {
+				t.Errorf("exitCode(%v) = %d, want %d", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPartialError(t *testing.T) {
+	notFound := &statusError{StatusCode: 404}
+	tests := []struct {
+		name          string
+		failed, total int
+		first         error
+		want          int
+	}{
+		{name: "some failed", failed: 1, total: 3, first: notFound, want: exitPartial},
+		{name: "all failed takes the first failure's code", failed: 2, total: 2, first: notFound, want: exitNotFound},
+		{name: "all failed without a first failure", failed: 2, total: 2, want: exitFailure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := partialError(errors.New("failed"), test.failed, test.total, test.first)
+			if got := exitCode(err); got != test.want {
+				t.Errorf("exit code = %d, want %d", got, test.want)
+			}
+		})
+	}
+}
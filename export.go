@@ -0,0 +1,149 @@
+package jiraattach
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// bundleMetadataFile is the name of the metadata file in an export bundle.
+const bundleMetadataFile = "metadata.json"
+
+// bundle describes an exported issue's attachments. It is written to
+// metadata.json alongside the downloaded files.
+type bundle struct {
+	Issue       string             `json:"issue"`
+	Summary     string             `json:"summary"`
+	Source      string             `json:"source"`
+	Exported    time.Time          `json:"exported"`
+	Attachments []bundleAttachment `json:"attachments"`
+}
+
+type bundleAttachment struct {
+	ID            string          `json:"id"`
+	Filename      string          `json:"filename"`
+	Path          string          `json:"path"`
+	Size          int64           `json:"size"`
+	MimeType      string          `json:"mime_type"`
+	SHA256        string          `json:"sha256"`
+	Author        User            `json:"author"`
+	Created       jiraTime        `json:"created"`
+	InDescription bool            `json:"in_description"`
+	Comments      []commentAnchor `json:"comments"`
+}
+
+// commentAnchor identifies a comment that links to or embeds an attachment.
+type commentAnchor struct {
+	ID      string   `json:"id"`
+	Author  User     `json:"author"`
+	Created jiraTime `json:"created"`
+}
+
+const exportUsage = `usage: jiraattach export key dir
+
+  Download every attachment on a Jira Issue into dir along with a
+  metadata.json file describing authors, timestamps, checksums and the
+  comments that reference each attachment.
+`
+
+// runExport implements the export command, downloading an issue's
+// attachments and their metadata into a directory.
+func runExport(config *Config, args []string) error {
+	fs := newFlagSet("export", exportUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return usageErrorf("key and dir are required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	dir := fs.Arg(1)
+	config = config.route(key)
+
+	c := config.client()
+	issue, err := c.issue(key, "summary", "description", "attachment")
+	if err != nil {
+		return fmt.Errorf("error fetching issue %v: %w", key, err)
+	}
+	comments, err := c.comments(key)
+	if err != nil {
+		return fmt.Errorf("error fetching comments on %v: %w", key, err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("error creating %v: %w", dir, err)
+	}
+
+	b := bundle{
+		Issue:    issue.Key,
+		Summary:  issue.Fields.Summary,
+		Source:   c.siteURL + "/browse/" + issue.Key,
+		Exported: time.Now(),
+	}
+	for _, a := range issue.Fields.Attachments {
+		ba := bundleAttachment{
+			ID:            a.ID,
+			Filename:      a.Filename,
+			Path:          a.ID + "-" + filepath.Base(a.Filename),
+			Size:          a.Size,
+			MimeType:      a.MimeType,
+			Author:        a.Author,
+			Created:       a.Created,
+			InDescription: referencesAttachment(issue.Fields.Description, a),
+			Comments:      []commentAnchor{},
+		}
+		for _, cm := range comments {
+			if referencesAttachment(cm.Body, a) {
+				ba.Comments = append(ba.Comments, commentAnchor{ID: cm.ID, Author: cm.Author, Created: cm.Created})
+			}
+		}
+		ba.SHA256, err = downloadFile(c, a, filepath.Join(dir, ba.Path))
+		if err != nil {
+			return err
+		}
+		b.Attachments = append(b.Attachments, ba)
+	}
+
+	metadata, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding metadata: %w", err)
+	}
+	return writeFile(filepath.Join(dir, bundleMetadataFile), metadata)
+}
+
+// downloadFile saves the attachment to path, setting its modification time
+// to when it was attached, and returns the hex encoded SHA-256 of its content.
+func downloadFile(c *client, a Attachment, path string) (string, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("error creating %v: %w", path, err)
+	}
+	h := sha256.New()
+	err = c.download(a, io.MultiWriter(f, h))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("error downloading %v: %w", a.Filename, err)
+	}
+	if !a.Created.IsZero() {
+		os.Chtimes(path, a.Created.Time, a.Created.Time)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func writeFile(path string, data []byte) error {
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error writing %v: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var (
+	galleryImages = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+	galleryVideos = map[string]bool{".webm": true, ".mp4": true, ".mov": true}
+)
+
+// galleryFile is a screenshot or video found in a test output directory.
+type galleryFile struct {
+	path  string
+	name  string
+	test  string
+	image bool
+}
+
+const galleryUsage = `usage: jiraattach gallery [-title=text] [-transcode] key dir
+
+  Attach the screenshots and videos in a Playwright or Cypress output
+  directory and post a single comment showing them grouped by test.
+`
+
+// runGallery implements the gallery command, attaching the screenshots and
+// videos of an end-to-end test run and posting a comment grouping them by
+// test.
+func runGallery(config *Config, args []string) error {
+	fs := newFlagSet("gallery", galleryUsage)
+	title := fs.String("title", "Test failure gallery", "heading of the gallery comment")
+	allowsecrets := fs.Bool("allow-secrets", config.AllowSecrets, "attach files even if they appear to contain secrets")
+	transcode := fs.Bool("transcode", config.Transcode.Enabled, "re-encode videos as H.264 with ffmpeg before attaching")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	config.AllowSecrets = *allowsecrets
+	config.Transcode.Enabled = *transcode
+	if fs.NArg() < 2 {
+		return usageErrorf("key and dir are required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	dir := fs.Arg(1)
+	config = config.route(key)
+
+	files, err := findGalleryFiles(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no screenshots or videos found in %v", dir)
+	}
+
+	groups := map[string][]galleryFile{}
+	var tests []string
+	for _, f := range files {
+		attachments, err := attachPath(config, key, f.path, f.name)
+		if err != nil {
+			return fmt.Errorf("error uploading %v: %w", f.path, err)
+		}
+		for _, a := range attachments {
+			f.name = a.Filename
+		}
+		if _, ok := groups[f.test]; !ok {
+			tests = append(tests, f.test)
+		}
+		groups[f.test] = append(groups[f.test], f)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "h3. %v\n", *title)
+	for _, test := range tests {
+		fmt.Fprintf(buf, "\nh4. %v\n", test)
+		for _, f := range groups[test] {
+			if f.image {
+				fmt.Fprintf(buf, "!%v|thumbnail! ", f.name)
+			} else {
+				fmt.Fprintf(buf, "[^%v] ", f.name)
+			}
+		}
+		buf.WriteString("\n")
+	}
+	if _, err := config.client().comment(key, buf.String(), commentOptions{}); err != nil {
+		return fmt.Errorf("error commenting on %v: %w", key, err)
+	}
+	return nil
+}
+
+// findGalleryFiles walks a Playwright or Cypress output directory for
+// screenshots and videos, working out which test each belongs to. Playwright
+// writes a directory per test, while Cypress names screenshots after the
+// test and videos after the spec.
+func findGalleryFiles(dir string) ([]galleryFile, error) {
+	var files []galleryFile
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		ext := strings.ToLower(filepath.Ext(path))
+		if !galleryImages[ext] && !galleryVideos[ext] {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		parts := strings.Split(rel, "/")
+		stem := strings.TrimSuffix(parts[len(parts)-1], filepath.Ext(path))
+
+		f := galleryFile{
+			path:  path,
+			name:  strings.Replace(rel, "/", "_", -1),
+			image: galleryImages[ext],
+		}
+		switch {
+		case contains(parts, "screenshots"):
+			f.test = strings.TrimSuffix(stem, " (failed)")
+		case contains(parts, "videos") || len(parts) == 1:
+			f.test = stem
+		default:
+			f.test = strings.Join(parts[:len(parts)-1], "/")
+		}
+		files = append(files, f)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %v: %w", dir, err)
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].test < files[j].test
+	})
+	return files, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
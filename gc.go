@@ -0,0 +1,79 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+)
+
+const gcUsage = `usage: jiraattach gc [-dry-run] [-yes] key
+
+  Delete attachments that are not linked or embedded in the description or
+  any comment of a Jira Issue, after asking for confirmation.
+`
+
+// runGC implements the gc command, deleting attachments that are not
+// referenced from an issue's description or comments.
+func runGC(config *Config, args []string) error {
+	fs := newFlagSet("gc", gcUsage)
+	dryrun := fs.Bool("dry-run", false, "report unreferenced attachments without deleting them")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+
+	c := config.client()
+	issue, err := c.issue(key, "description", "attachment")
+	if err != nil {
+		return fmt.Errorf("error fetching issue %v: %w", key, err)
+	}
+	comments, err := c.comments(key)
+	if err != nil {
+		return fmt.Errorf("error fetching comments on %v: %w", key, err)
+	}
+
+	unreferenced := unreferencedAttachments(issue, comments)
+	if len(unreferenced) == 0 {
+		fmt.Fprintf(os.Stderr, "no unreferenced attachments on %v\n", key)
+		return nil
+	}
+
+	for _, a := range unreferenced {
+		fmt.Printf("%v\t%v\t%v\n", a.ID, a.Size, a.Filename)
+	}
+	if *dryrun {
+		return nil
+	}
+	if !*yes && !confirm(fmt.Sprintf("Delete %d unreferenced attachments from %v?", len(unreferenced), key), false) {
+		return nil
+	}
+	for _, a := range unreferenced {
+		if err := c.deleteAttachment(key, a); err != nil {
+			return fmt.Errorf("error deleting attachment %v: %w", a.ID, err)
+		}
+	}
+	return nil
+}
+
+// unreferencedAttachments returns the attachments on the issue that neither
+// its description nor any of its comments link to or embed.
+func unreferencedAttachments(issue *Issue, comments []Comment) []Attachment {
+	var unreferenced []Attachment
+	for _, a := range issue.Fields.Attachments {
+		referenced := referencesAttachment(issue.Fields.Description, a)
+		for _, cm := range comments {
+			referenced = referenced || referencesAttachment(cm.Body, a)
+		}
+		if !referenced {
+			unreferenced = append(unreferenced, a)
+		}
+	}
+	return unreferenced
+}
@@ -0,0 +1,75 @@
+package jiraattach
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnreferencedAttachments(t *testing.T) {
+	c := &client{siteURL: "https://example.atlassian.net"}
+	log := Attachment{ID: "10", Filename: "build.log", Content: "https://example.atlassian.net/rest/api/3/attachment/content/10"}
+	shot := Attachment{ID: "11", Filename: "shot.png", Content: "https://example.atlassian.net/rest/api/3/attachment/content/11"}
+	other := Attachment{ID: "100", Filename: "other.txt", Content: "https://example.atlassian.net/rest/api/3/attachment/content/100"}
+
+	// A comment posted in ADF reads back through version 2 of the API with
+	// the links it was posted with in place of [^name] and !name!.
+	doc, err := json.Marshal(wikiToADF("Build log: [^build.log]\n!shot.png!", map[string]string{
+		log.Filename:  c.attachmentURL(log),
+		shot.Filename: c.attachmentURL(shot),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"href":"https://example.atlassian.net/secure/attachment/10/build.log"`, `"url":"https://example.atlassian.net/secure/attachment/11/shot.png"`} {
+		if !strings.Contains(string(doc), want) {
+			t.Fatalf("ADF comment %s doesn't link with %s", doc, want)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		description string
+		comments    []string
+		want        []Attachment
+	}{
+		{name: "nothing referenced", want: []Attachment{log, shot, other}},
+		{name: "wiki markup", comments: []string{"Build log: [^build.log]", "!shot.png|thumbnail!"}, want: []Attachment{other}},
+		{
+			name: "comment posted in ADF",
+			comments: []string{
+				"Build log: [build.log|https://example.atlassian.net/secure/attachment/10/build.log]\n\n!https://example.atlassian.net/secure/attachment/11/shot.png!",
+			},
+			want: []Attachment{other},
+		},
+		{
+			name:     "content URL",
+			comments: []string{"[build.log|https://example.atlassian.net/rest/api/3/attachment/content/10]"},
+			want:     []Attachment{shot, other},
+		},
+		{
+			name:        "content URL through the OAuth gateway",
+			description: "see https://api.atlassian.com/ex/jira/abc/rest/api/3/attachment/content/11",
+			want:        []Attachment{log, other},
+		},
+		{
+			name:     "an ID is not a prefix of another",
+			comments: []string{"[x|https://example.atlassian.net/secure/attachment/1000/x]", "https://example.atlassian.net/rest/api/3/attachment/content/1001"},
+			want:     []Attachment{log, shot, other},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issue := &Issue{Fields: IssueFields{Description: test.description, Attachments: []Attachment{log, shot, other}}}
+			var comments []Comment
+			for _, body := range test.comments {
+				comments = append(comments, Comment{Body: body})
+			}
+			got := unreferencedAttachments(issue, comments)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("unreferenced = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
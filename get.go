@@ -0,0 +1,80 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const getUsage = `usage: jiraattach get [-o=dir] key [pattern]
+
+  Download the attachments on a Jira Issue whose filenames match the glob
+  pattern, all of them by default, into dir or the current directory. Files
+  keep their names and are timestamped with when they were attached. When
+  several attachments share a name only the newest is downloaded.
+`
+
+// runGet implements the get command, downloading the attachments on an
+// issue whose filenames match a pattern.
+func runGet(config *Config, args []string) error {
+	fs := newFlagSet("get", getUsage)
+	dir := fs.String("o", ".", "directory to download the attachments into")
+	if err := parseInterspersed(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	pattern := "*"
+	if fs.NArg() > 1 {
+		pattern = fs.Arg(1)
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %v: %w", pattern, err)
+	}
+	config = config.route(key)
+
+	c := config.client()
+	attachments, err := c.attachments(key)
+	if err != nil {
+		return fmt.Errorf("error listing attachments on %v: %w", key, err)
+	}
+
+	// An issue can hold several attachments with the same name, such as
+	// build.log from every run of a job. Only the newest is downloaded.
+	newest := map[string]Attachment{}
+	var names []string
+	for _, a := range attachments {
+		name := filepath.Base(a.Filename)
+		if ok, _ := filepath.Match(pattern, name); !ok {
+			continue
+		}
+		prev, seen := newest[name]
+		if !seen {
+			names = append(names, name)
+		}
+		if !seen || a.Created.After(prev.Created.Time) {
+			newest[name] = a
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no attachments on %v match %v", key, pattern)
+	}
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("error creating %v: %w", *dir, err)
+	}
+	for _, name := range names {
+		a := newest[name]
+		path := filepath.Join(*dir, name)
+		if _, err := downloadFile(c, a, path); err != nil {
+			return err
+		}
+		fmt.Printf("%v  %v\n", path, formatSize(a.Size))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package jiraattach
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// historyEntry records a single successful upload.
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	Issue    string    `json:"issue"`
+	ID       string    `json:"id"`
+	Filename string    `json:"filename"`
+	Size     int64     `json:"size"`
+	URL      string    `json:"url"`
+}
+
+func historyPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// historyMu keeps parallel uploads from interleaving their history entries.
+var historyMu sync.Mutex
+
+// recordHistory appends the uploaded attachments to the history file.
+func recordHistory(key string, attachments []Attachment) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, a := range attachments {
+		entry := historyEntry{
+			Time:     time.Now(),
+			Issue:    key,
+			ID:       a.ID,
+			Filename: a.Filename,
+			Size:     a.Size,
+			URL:      a.Content,
+		}
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readHistory returns every recorded upload, oldest first.
+func readHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue // skip lines damaged by an interrupted write
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
+
+// recentIssues returns the latest upload to each issue in the history, most
+// recent first.
+func recentIssues() ([]historyEntry, error) {
+	entries, err := readHistory()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var recent []historyEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !seen[entries[i].Issue] {
+			seen[entries[i].Issue] = true
+			recent = append(recent, entries[i])
+		}
+	}
+	return recent, nil
+}
+
+const historyUsage = `usage: jiraattach history [-issue=key] [pattern]
+
+  List uploads made from this machine, optionally limited to one issue or to
+  filenames matching a glob pattern.
+`
+
+// runHistory implements the history command, printing past uploads.
+func runHistory(config *Config, args []string) error {
+	fs := newFlagSet("history", historyUsage)
+	issue := fs.String("issue", "", "only show uploads to this issue")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	pattern := fs.Arg(0)
+
+	entries, err := readHistory()
+	if err != nil {
+		return fmt.Errorf("error reading upload history: %w", err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, e := range entries {
+		if *issue != "" && e.Issue != *issue {
+			continue
+		}
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, e.Filename); !ok {
+				continue
+			}
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", e.Time.Format("2006-01-02 15:04"), e.Issue, e.Size, e.Filename, e.URL)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,132 @@
+package jiraattach
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const importUsage = `usage: jiraattach import [-no-comment] [-allow-secrets] dir key
+
+  Upload a bundle created by export to another Jira Issue, keeping the
+  original filenames, and post a comment recording where the attachments
+  came from.
+`
+
+// runImport implements the import command, uploading a bundle created by
+// export to another issue.
+func runImport(config *Config, args []string) error {
+	fs := newFlagSet("import", importUsage)
+	nocomment := fs.Bool("no-comment", false, "do not post a provenance comment")
+	allowsecrets := fs.Bool("allow-secrets", config.AllowSecrets, "attach files even if they appear to contain secrets")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	config.AllowSecrets = *allowsecrets
+	if fs.NArg() < 2 {
+		return usageErrorf("dir and key are required")
+	}
+	dir := fs.Arg(0)
+	key, err := config.issueKey(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+
+	metadata, err := ioutil.ReadFile(filepath.Join(dir, bundleMetadataFile))
+	if err != nil {
+		return fmt.Errorf("error reading bundle: %w", err)
+	}
+	var b bundle
+	if err := json.Unmarshal(metadata, &b); err != nil {
+		return fmt.Errorf("error reading bundle, %v: %w", bundleMetadataFile, err)
+	}
+
+	// Verify the whole bundle before uploading anything so a damaged bundle
+	// doesn't leave the issue with a partial copy.
+	for _, a := range b.Attachments {
+		if err := checkBundlePath(a.Path); err != nil {
+			return err
+		}
+		if err := checkName(a.Filename); err != nil {
+			return fmt.Errorf("invalid filename in bundle: %w", err)
+		}
+		sum, err := hashFile(filepath.Join(dir, a.Path))
+		if err != nil {
+			return fmt.Errorf("error reading attachment, %v: %w", a.Path, err)
+		}
+		if a.SHA256 != "" && sum != a.SHA256 {
+			return fmt.Errorf("checksum mismatch for %v, the bundle has been modified", a.Path)
+		}
+	}
+
+	for _, a := range b.Attachments {
+		file, err := os.Open(filepath.Join(dir, a.Path))
+		if err != nil {
+			return fmt.Errorf("error reading attachment, %v: %w", a.Path, err)
+		}
+		_, err = attachFile(config, key, a.Filename, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error uploading %v: %w", a.Filename, err)
+		}
+	}
+
+	if *nocomment || len(b.Attachments) == 0 {
+		return nil
+	}
+	if _, err := config.client().comment(key, provenanceComment(b), commentOptions{}); err != nil {
+		return fmt.Errorf("error commenting on %v: %w", key, err)
+	}
+	return nil
+}
+
+// volumePathPattern matches paths starting with a Windows drive letter.
+var volumePathPattern = regexp.MustCompile(`^[A-Za-z]:`)
+
+// checkBundlePath checks that path, read from a bundle's metadata, names a
+// file in the bundle directory itself, so that a crafted bundle can't upload
+// other files, such as ../../.ssh/id_rsa. Windows volume paths such as
+// C:secret.txt are refused on every platform, so a bundle is treated the
+// same wherever it is imported.
+func checkBundlePath(path string) error {
+	if path == "" || path == "." || filepath.IsAbs(path) || strings.ContainsAny(path, `/\`) ||
+		strings.Contains(path, "..") || path != filepath.Base(path) || volumePathPattern.MatchString(path) {
+		return fmt.Errorf("invalid path %q in bundle, expected a file in the bundle directory", path)
+	}
+	return nil
+}
+
+// provenanceComment describes where the attachments of an imported bundle
+// came from.
+func provenanceComment(b bundle) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Attachments imported from [%v|%v], exported %v:\n", b.Issue, b.Source, b.Exported.UTC().Format("2006-01-02 15:04 MST"))
+	for _, a := range b.Attachments {
+		fmt.Fprintf(buf, "* [^%v] originally attached by %v on %v (sha256 %v)\n",
+			a.Filename, a.Author.DisplayName, a.Created.UTC().Format("2006-01-02 15:04 MST"), a.SHA256)
+	}
+	return buf.String()
+}
+
+// hashFile returns the hex encoded SHA-256 of the file at path.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
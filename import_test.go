@@ -0,0 +1,39 @@
+package jiraattach
+
+import "testing"
+
+func TestCheckBundlePath(t *testing.T) {
+	tests := []struct {
+		path string
+		ok   bool
+	}{
+		{path: "report.pdf", ok: true},
+		{path: "build.log.gz", ok: true},
+		{path: "12:00 notes.txt", ok: true},
+		{path: ""},
+		{path: "."},
+		{path: ".."},
+		{path: "../id_rsa"},
+		{path: "../../.ssh/id_rsa"},
+		{path: "sub/report.pdf"},
+		{path: "./report.pdf"},
+		{path: "/etc/passwd"},
+		{path: `..\id_rsa`},
+		{path: `sub\report.pdf`},
+		{path: `C:\Windows\win.ini`},
+		{path: `C:secret.txt`},
+		{path: `c:/secret.txt`},
+		{path: `\\server\share\secret.txt`},
+	}
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			err := checkBundlePath(test.path)
+			if test.ok && err != nil {
+				t.Errorf("checkBundlePath(%q) = %v, want nil", test.path, err)
+			}
+			if !test.ok && err == nil {
+				t.Errorf("checkBundlePath(%q) = nil, want an error", test.path)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+package jiraattach
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// sendToName is the name of the entry added to the Windows Send To menu.
+const sendToName = "Jira issue.cmd"
+
+// sendToScript asks for an issue key and attaches each file Explorer passes
+// to it. %s is replaced by the path of the jiraattach executable.
+const sendToScript = `@echo off
+rem Installed by jiraattach integrate windows-sendto
+set /p KEY=Attach to Jira issue: 
+if "%%KEY%%"=="" exit /b
+"%s" attach -continue-on-error %%KEY%% %%* || pause
+`
+
+// quickActionName is the name of the Finder Quick Action, which is also the
+// name of its workflow bundle.
+const quickActionName = "Attach to Jira issue…"
+
+// quickActionScript asks for an issue key and attaches each file Finder
+// passes to it, reporting the outcome in a notification. %s is replaced by
+// the quoted path of the jiraattach executable.
+const quickActionScript = `key=$(osascript -e 'text returned of (display dialog "Attach to Jira issue:" default answer "")') || exit 0
+[ -n "$key" ] || exit 0
+log="$HOME/Library/Logs/jiraattach.log"
+if ! %s attach -continue-on-error "$key" "$@" >>"$log" 2>&1; then
+	osascript -e 'display notification "Some files could not be attached, see ~/Library/Logs/jiraattach.log" with title "jiraattach"'
+else
+	osascript -e "display notification \"Attached to $key\" with title \"jiraattach\""
+fi
+`
+
+const quickActionInfo = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>NSServices</key>
+	<array>
+		<dict>
+			<key>NSMenuItem</key>
+			<dict>
+				<key>default</key>
+				<string>%s</string>
+			</dict>
+			<key>NSMessage</key>
+			<string>runWorkflowAsService</string>
+			<key>NSRequiredContext</key>
+			<dict>
+				<key>NSApplicationIdentifier</key>
+				<string>com.apple.finder</string>
+			</dict>
+			<key>NSSendFileTypes</key>
+			<array>
+				<string>public.item</string>
+			</array>
+		</dict>
+	</array>
+</dict>
+</plist>
+`
+
+// quickActionWorkflow is an Automator workflow running a shell script with
+// the selected files as its arguments. %s is replaced by the script.
+const quickActionWorkflow = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>AMApplicationBuild</key>
+	<string>523</string>
+	<key>AMApplicationVersion</key>
+	<string>2.10</string>
+	<key>AMDocumentVersion</key>
+	<string>2</string>
+	<key>actions</key>
+	<array>
+		<dict>
+			<key>action</key>
+			<dict>
+				<key>AMAccepts</key>
+				<dict>
+					<key>Container</key>
+					<string>List</string>
+					<key>Optional</key>
+					<true/>
+					<key>Types</key>
+					<array>
+						<string>com.apple.cocoa.path</string>
+					</array>
+				</dict>
+				<key>AMActionVersion</key>
+				<string>2.0.3</string>
+				<key>AMApplication</key>
+				<array>
+					<string>Automator</string>
+				</array>
+				<key>AMProvides</key>
+				<dict>
+					<key>Container</key>
+					<string>List</string>
+					<key>Types</key>
+					<array>
+						<string>com.apple.cocoa.string</string>
+					</array>
+				</dict>
+				<key>ActionBundlePath</key>
+				<string>/System/Library/Automator/Run Shell Script.action</string>
+				<key>ActionName</key>
+				<string>Run Shell Script</string>
+				<key>ActionParameters</key>
+				<dict>
+					<key>COMMAND_STRING</key>
+					<string>%s</string>
+					<key>CheckedForUserDefaultShell</key>
+					<true/>
+					<key>inputMethod</key>
+					<integer>1</integer>
+					<key>shell</key>
+					<string>/bin/sh</string>
+					<key>source</key>
+					<string></string>
+				</dict>
+				<key>BundleIdentifier</key>
+				<string>com.apple.RunShellScript</string>
+				<key>CFBundleVersion</key>
+				<string>2.0.3</string>
+				<key>Class Name</key>
+				<string>RunShellScriptAction</string>
+			</dict>
+		</dict>
+	</array>
+	<key>connectors</key>
+	<dict/>
+	<key>workflowMetaData</key>
+	<dict>
+		<key>serviceInputTypeIdentifier</key>
+		<string>com.apple.Automator.fileSystemObject</string>
+		<key>serviceOutputTypeIdentifier</key>
+		<string>com.apple.Automator.nothing</string>
+		<key>serviceProcessesInput</key>
+		<integer>0</integer>
+		<key>workflowTypeIdentifier</key>
+		<string>com.apple.Automator.servicesMenu</string>
+	</dict>
+</dict>
+</plist>
+`
+
+const integrateUsage = `usage: jiraattach integrate [-remove] windows-sendto|macos-quick-action
+
+  Add a "Jira issue" entry to the Windows Send To menu, or an "Attach to
+  Jira issue…" Quick Action to the macOS Finder, that asks for an issue key
+  and attaches the selected files to it, or with -remove take it away again.
+`
+
+// runIntegrate implements the integrate command, adding jiraattach to the
+// desktop's file manager.
+func runIntegrate(config *Config, args []string) error {
+	fs := newFlagSet("integrate", integrateUsage)
+	remove := fs.Bool("remove", false, "remove the integration instead of installing it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("an integration is required, windows-sendto or macos-quick-action")
+	}
+	switch fs.Arg(0) {
+	case "windows-sendto":
+		return integrateSendTo(*remove)
+	case "macos-quick-action":
+		return integrateQuickAction(*remove)
+	default:
+		return fmt.Errorf("unknown integration %v, expected windows-sendto or macos-quick-action", fs.Arg(0))
+	}
+}
+
+// integrateSendTo installs a Send To entry that prompts for an issue key and
+// attaches the selected files to it.
+func integrateSendTo(remove bool) error {
+	if runtime.GOOS != "windows" {
+		return fmt.Errorf("windows-sendto is only available on Windows")
+	}
+	appdata := os.Getenv("APPDATA")
+	if appdata == "" {
+		return fmt.Errorf("unable to find the Send To folder, APPDATA is not set")
+	}
+	path := filepath.Join(appdata, "Microsoft", "Windows", "SendTo", sendToName)
+	if remove {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing %v: %w", path, err)
+		}
+		fmt.Printf("removed %v\n", path)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the jiraattach executable: %w", err)
+	}
+	script := fmt.Sprintf(sendToScript, exe)
+	script = strings.Replace(script, "\n", "\r\n", -1)
+	if err := ioutil.WriteFile(path, []byte(script), 0644); err != nil {
+		return fmt.Errorf("error writing %v: %w", path, err)
+	}
+	fmt.Printf("installed %v\n", path)
+	return nil
+}
+
+// integrateQuickAction installs a Finder Quick Action that prompts for an
+// issue key and attaches the selected files to it.
+func integrateQuickAction(remove bool) error {
+	if runtime.GOOS != "darwin" {
+		return fmt.Errorf("macos-quick-action is only available on macOS")
+	}
+	dir := filepath.Join(os.Getenv("HOME"), "Library", "Services", quickActionName+".workflow")
+	if remove {
+		if err := os.RemoveAll(dir); err != nil {
+			return fmt.Errorf("error removing %v: %w", dir, err)
+		}
+		fmt.Printf("removed %v\n", dir)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the jiraattach executable: %w", err)
+	}
+	script := fmt.Sprintf(quickActionScript, shellQuote(exe))
+	files := map[string]string{
+		"Info.plist":     fmt.Sprintf(quickActionInfo, xmlEscape(quickActionName)),
+		"document.wflow": fmt.Sprintf(quickActionWorkflow, xmlEscape(script)),
+	}
+	contents := filepath.Join(dir, "Contents")
+	if err := os.MkdirAll(contents, 0755); err != nil {
+		return fmt.Errorf("error creating %v: %w", dir, err)
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(contents, name), []byte(data), 0644); err != nil {
+			return fmt.Errorf("error writing %v: %w", dir, err)
+		}
+	}
+	fmt.Printf("installed %v\n", dir)
+	return nil
+}
+
+// shellQuote quotes s for use as a single word in a POSIX shell script.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// xmlEscape escapes s for use as XML character data.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
@@ -0,0 +1,130 @@
+package jiraattach
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// issueURLPattern matches the address of an issue as shown in a browser,
+// such as https://jira.example.com/browse/PROJ-123, capturing the Jira base
+// URL and the key.
+var issueURLPattern = regexp.MustCompile(`^(https?://[^?#]+?)/browse/([A-Z][A-Z0-9_]*-[0-9]+)/?(?:[?#].*)?$`)
+
+// issueURL is an issue given by its address rather than its key.
+type issueURL struct {
+	key  string
+	base string
+}
+
+// parseIssueURL returns the issue addressed by s, which may be a /browse/
+// link or a board link with a selectedIssue parameter. ok is false when s
+// isn't an issue URL.
+func parseIssueURL(s string) (issue issueURL, ok bool) {
+	if m := issueURLPattern.FindStringSubmatch(s); m != nil {
+		return issueURL{key: m[2], base: m[1]}, true
+	}
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return issue, false
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return issue, false
+	}
+	key := u.Query().Get("selectedIssue")
+	if !issueKeyPattern.MatchString(key) {
+		return issue, false
+	}
+	return issueURL{key: key, base: u.Scheme + "://" + u.Host}, true
+}
+
+// hasIssueURL reports whether any of args, or any key in a comma separated
+// list of them, is an issue URL. Such a URL may supply jira_url.
+func hasIssueURL(args []string) bool {
+	for _, arg := range args {
+		for _, part := range strings.Split(arg, ",") {
+			if _, ok := parseIssueURL(part); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// issueKey returns the key of the issue given as arg where a command
+// expects one, which may be the issue's URL instead. Only arguments in a
+// key's position are read as issues, so that URLs elsewhere, such as in a
+// comment or as a source to fetch, are left alone.
+func (c *Config) issueKey(arg string) (string, error) {
+	issue, ok := parseIssueURL(arg)
+	if ok {
+		if err := c.useIssueURLs([]issueURL{issue}); err != nil {
+			return "", err
+		}
+		arg = issue.key
+	}
+	if c.JiraURL == "" {
+		return "", usageErrorf("unable to open config file, %v", c.path)
+	}
+	return arg, nil
+}
+
+// useIssueURLs points the config at the instances of issues given by URL.
+// Without a jira_url the first issue's instance is used, see useIssueSite. An issue on the instance of a profile is
+// routed to it, and one on any other instance is refused rather than
+// sending it credentials meant for another.
+func (c *Config) useIssueURLs(issues []issueURL) error {
+	for _, issue := range issues {
+		if c.JiraURL == "" {
+			if err := c.useIssueSite(issue.base); err != nil {
+				return err
+			}
+		}
+		if siteKey(c.JiraURL) == siteKey(issue.base) {
+			continue
+		}
+		name := ""
+		for n, p := range c.Profiles {
+			if p.JiraURL != "" && siteKey(p.JiraURL) == siteKey(issue.base) {
+				name = n
+				break
+			}
+		}
+		if name == "" {
+			return usageErrorf("%v is on %v, which isn't jira_url or the jira_url of a profile", issue.key, issue.base)
+		}
+		if c.Routes == nil {
+			c.Routes = map[string]string{}
+		}
+		c.Routes[issue.key] = name
+	}
+	return nil
+}
+
+// useIssueSite makes base, the instance of an issue given by URL, jira_url
+// when the config has none, so a one-off command needs no config file. The
+// link may come from anyone, so the credentials and headers of the config,
+// which aren't tied to any site, are dropped; only those saved by login for
+// exactly this site, or those of a profile for it, are sent.
+func (c *Config) useIssueSite(base string) error {
+	if err := checkInsecureHTTP(base, c.AllowInsecureHTTP); err != nil {
+		return err
+	}
+	c.JiraURL = base
+	c.Auth, c.Headers = "", nil
+	c.loadKeyringAuth()
+	if c.Auth == "" {
+		for name, p := range c.Profiles {
+			if p.JiraURL != "" && p.sameSite(c) {
+				if err := c.useProfile(name); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	if c.Auth == "" && c.AuthType != authOAuth {
+		return usageErrorf("no credentials saved for %v; run jiraattach login for it, or set jira_url, rather than sending it those of the config", base)
+	}
+	return nil
+}
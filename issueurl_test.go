@@ -0,0 +1,87 @@
+package jiraattach
+
+import "testing"
+
+func TestIssueKeyCredentials(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		arg      string
+		wantKey  string
+		wantURL  string
+		wantAuth string
+		wantErr  bool
+	}{
+		{
+			name:    "config credentials aren't sent to a linked site",
+			config:  Config{Auth: "me:secret", Headers: map[string]string{"X-Token": "secret"}},
+			arg:     "https://jira.example.com.evil.test/browse/PROJ-1",
+			wantErr: true,
+		},
+		{
+			name: "profile for the linked site",
+			config: Config{
+				Auth:     "me:secret",
+				Profiles: map[string]Profile{"ops": {JiraURL: "https://ops.example.com", Auth: "ops:pw"}},
+			},
+			arg:      "https://ops.example.com/browse/OPS-7",
+			wantKey:  "OPS-7",
+			wantURL:  "https://ops.example.com",
+			wantAuth: "ops:pw",
+		},
+		{
+			name:     "oauth tokens are kept per site",
+			config:   Config{AuthType: authOAuth},
+			arg:      "https://jira.example.com/browse/PROJ-1",
+			wantKey:  "PROJ-1",
+			wantURL:  "https://jira.example.com",
+			wantAuth: "",
+		},
+		{
+			name:     "link to jira_url",
+			config:   Config{JiraURL: "https://jira.example.com", Auth: "me:secret"},
+			arg:      "https://jira.example.com/browse/PROJ-1",
+			wantKey:  "PROJ-1",
+			wantURL:  "https://jira.example.com",
+			wantAuth: "me:secret",
+		},
+		{
+			name:    "link to another site than jira_url",
+			config:  Config{JiraURL: "https://jira.example.com", Auth: "me:secret"},
+			arg:     "https://other.example.com/browse/PROJ-1",
+			wantErr: true,
+		},
+		{
+			name:     "plain key",
+			config:   Config{JiraURL: "https://jira.example.com", Auth: "me:secret"},
+			arg:      "PROJ-1",
+			wantKey:  "PROJ-1",
+			wantURL:  "https://jira.example.com",
+			wantAuth: "me:secret",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			withStateDir(t, func() {
+				c := test.config
+				key, err := c.issueKey(test.arg)
+				if test.wantErr {
+					if exitCode(err) != exitUsage {
+						t.Fatalf("issueKey(%q) error = %v, want a usage error", test.arg, err)
+					}
+					if test.config.JiraURL == "" && (c.Auth != "" || c.Headers != nil) {
+						t.Errorf("config kept auth %q and headers %v for %v", c.Auth, c.Headers, c.JiraURL)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("issueKey(%q): %v", test.arg, err)
+				}
+				if key != test.wantKey || c.JiraURL != test.wantURL || c.Auth != test.wantAuth {
+					t.Errorf("issueKey(%q) = %q on %v with auth %q, want %q on %v with auth %q",
+						test.arg, key, c.JiraURL, c.Auth, test.wantKey, test.wantURL, test.wantAuth)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package jiraattach
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+// The Jira types are those of the jira package, which makes the requests.
+type (
+	jiraTime       = jira.Time
+	User           = jira.User
+	Attachment     = jira.Attachment
+	Comment        = jira.Comment
+	Issue          = jira.Issue
+	IssueFields    = jira.IssueFields
+	Status         = jira.Status
+	StatusCategory = jira.StatusCategory
+)
+
+// referencesAttachment reports whether the wiki markup links to or embeds
+// the attachment, by name as in [^name] or !name! and !name|thumbnail!, or
+// by URL as comments posted in ADF read back, as in [name|url] or !url!.
+func referencesAttachment(markup string, a Attachment) bool {
+	if strings.Contains(markup, "[^"+a.Filename+"]") ||
+		strings.Contains(markup, "!"+a.Filename+"!") ||
+		strings.Contains(markup, "!"+a.Filename+"|") {
+		return true
+	}
+	if a.ID == "" {
+		return false
+	}
+	// Site links are /secure/attachment/{id}/{name}, and content URLs,
+	// through the OAuth gateway too, end in /attachment/content/{id}.
+	return regexp.MustCompile(`/(?:secure/attachment|attachment/content)/` + regexp.QuoteMeta(a.ID) + `(?:[/?#|!\]\s]|$)`).MatchString(markup)
+}
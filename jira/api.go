@@ -0,0 +1,248 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// API is the part of the Jira REST API that Client implements. Code that
+// depends on API rather than *Client can be tested with a fake.
+type API interface {
+	AttachFile(ctx context.Context, key, filename string, r io.Reader, size int64) ([]Attachment, error)
+	AttachFileWithType(ctx context.Context, key, filename, contentType string, r io.Reader, size int64) ([]Attachment, error)
+	ListAttachments(ctx context.Context, key string) ([]Attachment, error)
+	Attachment(ctx context.Context, id string) (*Attachment, error)
+	DeleteAttachment(ctx context.Context, id string) error
+	Download(ctx context.Context, a Attachment, w io.Writer) error
+	AddComment(ctx context.Context, key, body string, opts *CommentOptions) (*Comment, error)
+	AddADFComment(ctx context.Context, key string, doc interface{}, opts *CommentOptions) (*Comment, error)
+	Comments(ctx context.Context, key string) ([]Comment, error)
+	Issue(ctx context.Context, key string, fields ...string) (*Issue, error)
+	UpdateIssue(ctx context.Context, key string, edit interface{}) error
+	Search(ctx context.Context, jql string, fields ...string) ([]Issue, error)
+	Myself(ctx context.Context) (*User, error)
+}
+
+var _ API = (*Client)(nil)
+
+// Issue fetches the issue identified by key. When fields are given only
+// those fields are returned.
+func (c *Client) Issue(ctx context.Context, key string, fields ...string) (*Issue, error) {
+	path := "/rest/api/2/issue/" + url.PathEscape(key)
+	if len(fields) > 0 {
+		path += "?fields=" + url.QueryEscape(strings.Join(fields, ","))
+	}
+	req, err := c.NewRequest(ctx, "GET", path, nil)
+	if err != nil {
+		return nil, err
+	}
+	issue := &Issue{}
+	if err := c.Do(req, issue); err != nil {
+		return nil, err
+	}
+	return issue, nil
+}
+
+// Myself returns the user the client is authenticated as.
+func (c *Client) Myself(ctx context.Context) (*User, error) {
+	req, err := c.NewRequest(ctx, "GET", "/rest/api/2/myself", nil)
+	if err != nil {
+		return nil, err
+	}
+	user := &User{}
+	if err := c.Do(req, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ListAttachments lists the attachments on the issue identified by key.
+func (c *Client) ListAttachments(ctx context.Context, key string) ([]Attachment, error) {
+	issue, err := c.Issue(ctx, key, "attachment")
+	if err != nil {
+		return nil, err
+	}
+	return issue.Fields.Attachments, nil
+}
+
+// Attachment fetches the metadata of an attachment by its ID.
+func (c *Client) Attachment(ctx context.Context, id string) (*Attachment, error) {
+	req, err := c.NewRequest(ctx, "GET", "/rest/api/2/attachment/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	a := &Attachment{}
+	if err := c.Do(req, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAttachment removes an attachment by its ID.
+func (c *Client) DeleteAttachment(ctx context.Context, id string) error {
+	req, err := c.NewRequest(ctx, "DELETE", "/rest/api/2/attachment/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	return c.Do(req, nil)
+}
+
+// Download writes the content of the attachment to w.
+func (c *Client) Download(ctx context.Context, a Attachment, w io.Writer) error {
+	req, err := c.NewRequest(ctx, "GET", a.Content, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("error reading response body: %w", err)
+	}
+	return nil
+}
+
+// CommentOptions control who can see a comment and set properties on it.
+type CommentOptions struct {
+	Visibility *Visibility
+	Properties []EntityProperty
+}
+
+// Visibility restricts a comment to a project role or group.
+type Visibility struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// EntityProperty is a property stored with a comment, such as
+// sd.public.comment which makes a Jira Service Management comment internal.
+type EntityProperty struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// AddComment adds a comment with the given wiki markup body to the issue.
+// opts may be nil.
+func (c *Client) AddComment(ctx context.Context, key, body string, opts *CommentOptions) (*Comment, error) {
+	comment := &Comment{}
+	if err := c.postComment(ctx, "/rest/api/2/issue/"+url.PathEscape(key)+"/comment", body, opts, comment); err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+// AddADFComment adds a comment whose body is an Atlassian Document Format
+// document to the issue with version 3 of the API, as Jira Cloud expects.
+// The returned comment's Body is left empty since Jira returns the body as
+// ADF. opts may be nil.
+func (c *Client) AddADFComment(ctx context.Context, key string, doc interface{}, opts *CommentOptions) (*Comment, error) {
+	var posted struct {
+		ID      string `json:"id"`
+		Author  User   `json:"author"`
+		Created Time   `json:"created"`
+		Updated Time   `json:"updated"`
+	}
+	if err := c.postComment(ctx, "/rest/api/3/issue/"+url.PathEscape(key)+"/comment", doc, opts, &posted); err != nil {
+		return nil, err
+	}
+	return &Comment{ID: posted.ID, Author: posted.Author, Created: posted.Created, Updated: posted.Updated}, nil
+}
+
+func (c *Client) postComment(ctx context.Context, path string, body interface{}, opts *CommentOptions, v interface{}) error {
+	payload := map[string]interface{}{"body": body}
+	if opts != nil && opts.Visibility != nil {
+		payload["visibility"] = opts.Visibility
+	}
+	if opts != nil && len(opts.Properties) > 0 {
+		payload["properties"] = opts.Properties
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding comment: %w", err)
+	}
+	req, err := c.NewRequest(ctx, "POST", path, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.Do(req, v)
+}
+
+// Comments returns every comment on the issue, oldest first.
+func (c *Client) Comments(ctx context.Context, key string) ([]Comment, error) {
+	var comments []Comment
+	for {
+		path := fmt.Sprintf("/rest/api/2/issue/%v/comment?startAt=%d", url.PathEscape(key), len(comments))
+		req, err := c.NewRequest(ctx, "GET", path, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Total    int       `json:"total"`
+			Comments []Comment `json:"comments"`
+		}
+		if err := c.Do(req, &page); err != nil {
+			return nil, err
+		}
+		comments = append(comments, page.Comments...)
+		if len(page.Comments) == 0 || len(comments) >= page.Total {
+			return comments, nil
+		}
+	}
+}
+
+// UpdateIssue applies an edit, in the form accepted by the edit issue API,
+// to the issue identified by key.
+func (c *Client) UpdateIssue(ctx context.Context, key string, edit interface{}) error {
+	payload, err := json.Marshal(edit)
+	if err != nil {
+		return fmt.Errorf("error encoding issue update: %w", err)
+	}
+	req, err := c.NewRequest(ctx, "PUT", "/rest/api/2/issue/"+url.PathEscape(key), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.Do(req, nil)
+}
+
+// Search returns every issue matching the JQL query, fetching the given
+// fields and following pagination until all results have been read.
+func (c *Client) Search(ctx context.Context, jql string, fields ...string) ([]Issue, error) {
+	var issues []Issue
+	for {
+		q := url.Values{}
+		q.Set("jql", jql)
+		q.Set("fields", strings.Join(fields, ","))
+		q.Set("startAt", fmt.Sprint(len(issues)))
+		q.Set("maxResults", "100")
+		req, err := c.NewRequest(ctx, "GET", "/rest/api/2/search?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Total  int     `json:"total"`
+			Issues []Issue `json:"issues"`
+		}
+		if err := c.Do(req, &page); err != nil {
+			return nil, err
+		}
+		issues = append(issues, page.Issues...)
+		if len(page.Issues) == 0 || len(issues) >= page.Total {
+			return issues, nil
+		}
+	}
+}
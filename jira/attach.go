@@ -0,0 +1,182 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// AttachFile uploads the contents of r, size bytes long or -1 when unknown,
+// to the issue as filename and returns the attachments Jira created. The
+// file is streamed rather than read into memory. The request can only be
+// retried by HTTPClient when r can be rewound, see Rewind. The file's
+// Content-Type is guessed from the extension of filename.
+func (c *Client) AttachFile(ctx context.Context, key, filename string, r io.Reader, size int64) ([]Attachment, error) {
+	return c.AttachFileWithType(ctx, key, filename, "", r, size)
+}
+
+// AttachFileWithType is AttachFile sending contentType as the file's
+// Content-Type, which decides how Jira previews it. An empty contentType is
+// guessed from the extension of filename, falling back to
+// application/octet-stream.
+func (c *Client) AttachFileWithType(ctx context.Context, key, filename, contentType string, r io.Reader, size int64) ([]Attachment, error) {
+	if contentType == "" {
+		contentType = ContentTypeByName(filename)
+	}
+	body, err := newFileBody(filename, contentType, r, size)
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.NewRequest(ctx, "POST", "/rest/api/2/issue/"+url.PathEscape(key)+"/attachments", body.reader())
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = body.length
+	if rewind := rewindFunc(r); rewind != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			if err := rewind(); err != nil {
+				return nil, fmt.Errorf("error rewinding attachment: %w", err)
+			}
+			return ioutil.NopCloser(body.reader()), nil
+		}
+	}
+	req.Header.Set("Content-Type", body.contentType)
+	req.Header.Set("X-Atlassian-Token", "nocheck") // Disable XSRF verification
+	var attachments []Attachment
+	if err := c.Do(req, &attachments); err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// fileBody is the multipart form used to upload an attachment. Only the
+// form's header and trailer are held in memory; the file itself is streamed
+// from r as the request is sent, so memory use doesn't grow with its size.
+type fileBody struct {
+	head, tail  []byte
+	r           io.Reader
+	contentType string
+	length      int64
+}
+
+// ContentTypeByName returns the MIME type for the extension of filename, or
+// application/octet-stream when it isn't known.
+func ContentTypeByName(filename string) string {
+	if t := mime.TypeByExtension(path.Ext(filename)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// newFileBody builds the form for uploading size bytes of r as filename. The
+// length of the form is unknown when size is negative, and the request is
+// then sent chunked.
+func newFileBody(filename, contentType string, r io.Reader, size int64) (*fileBody, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	if _, err := w.CreatePart(formFileHeader(filename, contentType)); err != nil {
+		return nil, fmt.Errorf("error attaching file to form: %w", err)
+	}
+	head := append([]byte(nil), buf.Bytes()...)
+	buf.Reset()
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error writing form body: %w", err)
+	}
+	b := &fileBody{head: head, tail: buf.Bytes(), r: r, contentType: w.FormDataContentType(), length: -1}
+	if size >= 0 {
+		b.length = int64(len(b.head)) + size + int64(len(b.tail))
+	}
+	return b, nil
+}
+
+// formFileHeader returns the header of the form part holding a file named
+// filename. The name is sent as UTF-8 with quotes and line breaks
+// percent-encoded, as browsers send it and Jira expects, and names that
+// aren't plain ASCII are also given in filename* as RFC 5987 describes, for
+// servers and proxies that only trust that.
+func formFileHeader(filename, contentType string) textproto.MIMEHeader {
+	quoted := strings.NewReplacer(`"`, "%22", "\r", "%0D", "\n", "%0A").Replace(filename)
+	disposition := `form-data; name="file"; filename="` + quoted + `"`
+	for i := 0; i < len(filename); i++ {
+		if filename[i] < ' ' || filename[i] > '~' {
+			disposition += "; filename*=UTF-8''" + encodeRFC5987(filename)
+			break
+		}
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", disposition)
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+// encodeRFC5987 percent-encodes every byte of s but the attr-char set of
+// RFC 5987.
+func encodeRFC5987(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// reader returns the form from the start, reading the file from wherever r
+// currently is.
+func (b *fileBody) reader() io.Reader {
+	return io.MultiReader(bytes.NewReader(b.head), b.r, bytes.NewReader(b.tail))
+}
+
+// Rewinder is implemented by readers that wrap another reader and can
+// return to where they started reading it.
+type Rewinder interface {
+	Rewind() error
+}
+
+// Rewind returns r to where reading started, read bytes ago, which lets a
+// failed upload be sent again. Rewinding a reader that hasn't been read
+// checks whether it can be rewound at all; stdin usually can't.
+func Rewind(r io.Reader, read int64) error {
+	switch r := r.(type) {
+	case Rewinder:
+		return r.Rewind()
+	case io.Seeker:
+		_, err := r.Seek(-read, io.SeekCurrent)
+		return err
+	}
+	return fmt.Errorf("attachment can't be read again")
+}
+
+// rewindFunc returns a function that returns r to where it is now, or nil
+// when r can't be rewound. Rewinders are asked to rewind before anything
+// has been read, which tells whether they can.
+func rewindFunc(r io.Reader) func() error {
+	switch r := r.(type) {
+	case Rewinder:
+		if r.Rewind() != nil {
+			return nil
+		}
+		return r.Rewind
+	case io.Seeker:
+		start, err := r.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil
+		}
+		return func() error {
+			_, err := r.Seek(start, io.SeekStart)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package jira is a small client for the parts of the Jira REST API that
+// jiraattach uses: attaching files, commenting and reading issues.
+//
+//	c := jira.NewClient("https://example.atlassian.net")
+//	c.Authenticate = jira.BasicAuth("me@example.com", token)
+//	f, _ := os.Open("build.log")
+//	attachments, err := c.AttachFile(ctx, "PROJ-1", "build.log", f, -1)
+//
+// Client implements API, which code using it can depend on instead so a
+// fake can stand in for Jira in tests.
+package jira
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// Doer sends HTTP requests. *http.Client is a Doer, and wrappers can add
+// retries, logging or rate limiting.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client is a Jira REST API client.
+type Client struct {
+	// BaseURL is the URL of the Jira instance, such as
+	// https://example.atlassian.net.
+	BaseURL string
+	// HTTPClient sends the requests, http.DefaultClient when nil.
+	HTTPClient Doer
+	// Authenticate adds credentials to each request. Requests are sent
+	// anonymously when it is nil.
+	Authenticate func(req *http.Request) error
+	// UserAgent is sent as the User-Agent header when set.
+	UserAgent string
+}
+
+// NewClient returns a client for the Jira instance at baseURL.
+func NewClient(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// BasicAuth authenticates requests with a username, or email address for
+// Jira Cloud, and a password or API token.
+func BasicAuth(user, pass string) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		req.SetBasicAuth(user, pass)
+		return nil
+	}
+}
+
+// BearerAuth authenticates requests with a Personal Access Token or OAuth
+// access token.
+func BearerAuth(token string) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// NewRequest creates an authenticated request for the given API path,
+// which is cancelled along with ctx. Absolute URLs, such as attachment
+// content links, are used as is.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	u := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		u = c.BaseURL + path
+	}
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	if c.Authenticate != nil {
+		if err := c.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+	return req, nil
+}
+
+// Send sends req, transparently decompressing gzip encoded responses.
+// Metadata heavy responses such as searches compress very well, which
+// matters over slow links. The response status isn't checked.
+func (c *Client) Send(req *http.Request) (*http.Response, error) {
+	var hc Doer = http.DefaultClient
+	if c.HTTPClient != nil {
+		hc = c.HTTPClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, &SendError{Err: err}
+	}
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("error decompressing response: %w", err)
+		}
+		resp.Body = &gzipBody{Reader: zr, body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+	return resp, nil
+}
+
+// gzipBody decompresses a response body, closing the underlying body when
+// it is closed.
+type gzipBody struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (b *gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// Do sends req and, when v is not nil, decodes the JSON response into v.
+// Responses with a status outside 2xx are returned as a *StatusError.
+func (c *Client) Do(req *http.Request, v interface{}) error {
+	resp, err := c.Send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return newStatusError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// StatusError is returned when Jira responds with a non-2xx status code.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func newStatusError(resp *http.Response) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading error-response body: %w", err)
+	}
+	return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+}
+
+// SendError is returned when a request couldn't be sent or its response
+// couldn't be read, such as when Jira can't be reached.
+type SendError struct {
+	Err error
+}
+
+func (e *SendError) Error() string {
+	return "error sending request: " + e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StatusError) Error() string {
+	if e.StatusCode == http.StatusTooManyRequests {
+		return fmt.Sprintf("rate limited by Jira, request failed with status code, %d\n%s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("request failed with status code, %d\n%s", e.StatusCode, e.Body)
+}
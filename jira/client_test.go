@@ -0,0 +1,651 @@
+package jira
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeResponse is a canned answer to a request, or the error sending it.
+type fakeResponse struct {
+	status int
+	header http.Header
+	body   string
+	err    error
+}
+
+// fakeDoer stands in for Jira, answering each request with the next of its
+// responses and recording the requests and their bodies.
+type fakeDoer struct {
+	responses []fakeResponse
+	requests  []*http.Request
+	bodies    []string
+}
+
+func (d *fakeDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requests = append(d.requests, req)
+	body := ""
+	if req.Body != nil {
+		b, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	d.bodies = append(d.bodies, body)
+	if len(d.responses) == 0 {
+		return nil, errors.New("unexpected request to " + req.URL.String())
+	}
+	r := d.responses[0]
+	d.responses = d.responses[1:]
+	if r.err != nil {
+		return nil, r.err
+	}
+	header := r.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: r.status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(r.body)),
+		Request:    req,
+	}, nil
+}
+
+// newFakeClient returns a client for https://jira.example.com whose
+// requests are answered by responses.
+func newFakeClient(responses ...fakeResponse) (*Client, *fakeDoer) {
+	d := &fakeDoer{responses: responses}
+	c := NewClient("https://jira.example.com/")
+	c.HTTPClient = d
+	return c, d
+}
+
+func gzipped(s string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.String()
+}
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		name      string
+		auth      func(*http.Request) error
+		userAgent string
+		path      string
+		wantURL   string
+		wantAuth  string
+		wantErr   bool
+	}{
+		{name: "API path", path: "/rest/api/2/myself", wantURL: "https://jira.example.com/rest/api/2/myself"},
+		{
+			name:    "absolute URL",
+			path:    "https://files.example.com/secure/attachment/1/a.txt",
+			wantURL: "https://files.example.com/secure/attachment/1/a.txt",
+		},
+		{
+			name:     "basic auth",
+			auth:     BasicAuth("me@example.com", "token"),
+			path:     "/rest/api/2/myself",
+			wantURL:  "https://jira.example.com/rest/api/2/myself",
+			wantAuth: "Basic bWVAZXhhbXBsZS5jb206dG9rZW4=",
+		},
+		{
+			name:     "bearer auth",
+			auth:     BearerAuth("pat"),
+			path:     "/rest/api/2/myself",
+			wantURL:  "https://jira.example.com/rest/api/2/myself",
+			wantAuth: "Bearer pat",
+		},
+		{
+			name:      "user agent",
+			userAgent: "jiraattach/1.2.3",
+			path:      "/rest/api/2/myself",
+			wantURL:   "https://jira.example.com/rest/api/2/myself",
+		},
+		{
+			name:    "failing auth",
+			auth:    func(*http.Request) error { return errors.New("no token") },
+			path:    "/rest/api/2/myself",
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := NewClient("https://jira.example.com/")
+			c.Authenticate = test.auth
+			c.UserAgent = test.userAgent
+			req, err := c.NewRequest(context.Background(), "GET", test.path, nil)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("NewRequest succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewRequest: %v", err)
+			}
+			if got := req.URL.String(); got != test.wantURL {
+				t.Errorf("URL = %q, want %q", got, test.wantURL)
+			}
+			if got := req.Header.Get("Authorization"); got != test.wantAuth {
+				t.Errorf("Authorization = %q, want %q", got, test.wantAuth)
+			}
+			if got := req.Header.Get("User-Agent"); got != test.userAgent {
+				t.Errorf("User-Agent = %q, want %q", got, test.userAgent)
+			}
+			if got := req.Header.Get("Accept-Encoding"); got != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want gzip", got)
+			}
+		})
+	}
+}
+
+func TestDo(t *testing.T) {
+	sendErr := errors.New("connection refused")
+	tests := []struct {
+		name       string
+		response   fakeResponse
+		want       *User
+		wantStatus int
+		wantSend   bool
+		wantErr    string
+	}{
+		{
+			name:     "decodes JSON",
+			response: fakeResponse{status: 200, body: `{"displayName": "Me", "accountId": "1"}`},
+			want:     &User{DisplayName: "Me", AccountID: "1"},
+		},
+		{
+			name: "decompresses gzip",
+			response: fakeResponse{
+				status: 200,
+				header: http.Header{"Content-Encoding": {"gzip"}},
+				body:   gzipped(`{"displayName": "Zipped"}`),
+			},
+			want: &User{DisplayName: "Zipped"},
+		},
+		{
+			name:       "not found",
+			response:   fakeResponse{status: 404, body: `{"errorMessages": ["Issue does not exist"]}`},
+			wantStatus: 404,
+			wantErr:    "request failed with status code, 404\n{\"errorMessages\": [\"Issue does not exist\"]}",
+		},
+		{
+			name:       "rate limited",
+			response:   fakeResponse{status: 429},
+			wantStatus: 429,
+			wantErr:    "rate limited by Jira, request failed with status code, 429",
+		},
+		{
+			name:     "send failure",
+			response: fakeResponse{err: sendErr},
+			wantSend: true,
+			wantErr:  "error sending request: connection refused",
+		},
+		{
+			name:     "invalid JSON",
+			response: fakeResponse{status: 200, body: "<html>"},
+			wantErr:  "error decoding response",
+		},
+		{
+			name:     "invalid gzip",
+			response: fakeResponse{status: 200, header: http.Header{"Content-Encoding": {"gzip"}}, body: "plain"},
+			wantErr:  "error decompressing response",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, _ := newFakeClient(test.response)
+			got, err := c.Myself(context.Background())
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Myself error = %v, want %q", err, test.wantErr)
+				}
+				var status *StatusError
+				if errors.As(err, &status) != (test.wantStatus != 0) || status != nil && status.StatusCode != test.wantStatus {
+					t.Errorf("Myself error = %#v, want status %d", err, test.wantStatus)
+				}
+				var send *SendError
+				if errors.As(err, &send) != test.wantSend {
+					t.Errorf("Myself error = %#v, want a SendError %v", err, test.wantSend)
+				}
+				if test.wantSend && !errors.Is(err, sendErr) {
+					t.Errorf("Myself error = %v, doesn't wrap %v", err, sendErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Myself: %v", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Myself = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDownload(t *testing.T) {
+	tests := []struct {
+		name     string
+		response fakeResponse
+		want     string
+		wantErr  bool
+	}{
+		{name: "content", response: fakeResponse{status: 200, body: "file contents"}, want: "file contents"},
+		{name: "gzipped content", response: fakeResponse{status: 200, header: http.Header{"Content-Encoding": {"gzip"}}, body: gzipped("zipped")}, want: "zipped"},
+		{name: "forbidden", response: fakeResponse{status: 403, body: "no"}, wantErr: true},
+		{name: "redirect isn't content", response: fakeResponse{status: 303}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, d := newFakeClient(test.response)
+			var buf bytes.Buffer
+			a := Attachment{Content: "https://jira.example.com/secure/attachment/10/a.txt"}
+			err := c.Download(context.Background(), a, &buf)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Download error = %v, want error %v", err, test.wantErr)
+			}
+			if got := d.requests[0].URL.String(); got != a.Content {
+				t.Errorf("requested %q, want %q", got, a.Content)
+			}
+			if buf.String() != test.want {
+				t.Errorf("downloaded %q, want %q", buf.String(), test.want)
+			}
+		})
+	}
+}
+
+// unseekable hides whether a reader can seek.
+type unseekable struct {
+	io.Reader
+}
+
+func TestAttachFile(t *testing.T) {
+	tests := []struct {
+		name            string
+		filename        string
+		contentType     string
+		r               io.Reader
+		size            int64
+		wantDisposition string
+		wantType        string
+		wantLength      bool
+		wantGetBody     bool
+	}{
+		{
+			name:            "unknown extension",
+			filename:        "build.jaunknown",
+			r:               strings.NewReader("log line\n"),
+			size:            9,
+			wantDisposition: `form-data; name="file"; filename="build.jaunknown"`,
+			wantType:        "application/octet-stream",
+			wantLength:      true,
+			wantGetBody:     true,
+		},
+		{
+			name:            "type from the extension",
+			filename:        "report.html",
+			r:               strings.NewReader("<p>hi</p>"),
+			size:            9,
+			wantDisposition: `form-data; name="file"; filename="report.html"`,
+			wantType:        "text/html; charset=utf-8",
+			wantLength:      true,
+			wantGetBody:     true,
+		},
+		{
+			name:            "explicit type",
+			filename:        "trace.dat",
+			contentType:     "application/json",
+			r:               strings.NewReader("{}"),
+			size:            2,
+			wantDisposition: `form-data; name="file"; filename="trace.dat"`,
+			wantType:        "application/json",
+			wantLength:      true,
+			wantGetBody:     true,
+		},
+		{
+			name:            "quotes and line breaks",
+			filename:        "a \"b\"\nc.txt",
+			r:               strings.NewReader("x"),
+			size:            1,
+			wantDisposition: `form-data; name="file"; filename="a %22b%22%0Ac.txt"; filename*=UTF-8''a%20%22b%22%0Ac.txt`,
+			wantType:        "text/plain; charset=utf-8",
+			wantLength:      true,
+			wantGetBody:     true,
+		},
+		{
+			name:            "non-ASCII name",
+			filename:        "résumé.txt",
+			r:               strings.NewReader("x"),
+			size:            1,
+			wantDisposition: `form-data; name="file"; filename="résumé.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`,
+			wantType:        "text/plain; charset=utf-8",
+			wantLength:      true,
+			wantGetBody:     true,
+		},
+		{
+			name:            "stream of unknown size",
+			filename:        "stdin.txt",
+			r:               unseekable{strings.NewReader("piped")},
+			size:            -1,
+			wantDisposition: `form-data; name="file"; filename="stdin.txt"`,
+			wantType:        "text/plain; charset=utf-8",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, d := newFakeClient(fakeResponse{status: 200, body: `[{"id": "10", "filename": "f"}]`})
+			content, _ := ioutil.ReadAll(test.r)
+			if err := Rewind(test.r, int64(len(content))); err != nil {
+				// Unseekable readers are read again from a copy.
+				test.r = unseekable{bytes.NewReader(content)}
+			}
+			attachments, err := c.AttachFileWithType(context.Background(), "PROJ-1", test.filename, test.contentType, test.r, test.size)
+			if err != nil {
+				t.Fatalf("AttachFileWithType: %v", err)
+			}
+			if len(attachments) != 1 || attachments[0].ID != "10" {
+				t.Errorf("attachments = %+v, want the one created", attachments)
+			}
+
+			req := d.requests[0]
+			if req.Method != "POST" || req.URL.Path != "/rest/api/2/issue/PROJ-1/attachments" {
+				t.Errorf("request = %v %v, want POST to the issue's attachments", req.Method, req.URL.Path)
+			}
+			if got := req.Header.Get("X-Atlassian-Token"); got != "nocheck" {
+				t.Errorf("X-Atlassian-Token = %q, want nocheck", got)
+			}
+			if got := req.ContentLength == int64(len(d.bodies[0])); got != test.wantLength {
+				t.Errorf("ContentLength = %d for a body of %d bytes, want it known %v", req.ContentLength, len(d.bodies[0]), test.wantLength)
+			}
+			if got := req.GetBody != nil; got != test.wantGetBody {
+				t.Errorf("GetBody set = %v, want %v", got, test.wantGetBody)
+			}
+
+			_, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+			if err != nil {
+				t.Fatalf("Content-Type: %v", err)
+			}
+			part, err := multipart.NewReader(strings.NewReader(d.bodies[0]), params["boundary"]).NextPart()
+			if err != nil {
+				t.Fatalf("reading form: %v", err)
+			}
+			if got := part.Header.Get("Content-Disposition"); got != test.wantDisposition {
+				t.Errorf("Content-Disposition = %q, want %q", got, test.wantDisposition)
+			}
+			if got := part.Header.Get("Content-Type"); got != test.wantType {
+				t.Errorf("Content-Type = %q, want %q", got, test.wantType)
+			}
+			if got, _ := ioutil.ReadAll(part); string(got) != string(content) {
+				t.Errorf("file = %q, want %q", got, content)
+			}
+
+			if test.wantGetBody {
+				body, err := req.GetBody()
+				if err != nil {
+					t.Fatalf("GetBody: %v", err)
+				}
+				if again, _ := ioutil.ReadAll(body); string(again) != d.bodies[0] {
+					t.Errorf("GetBody = %q, want the body sent again", again)
+				}
+			}
+		})
+	}
+}
+
+// rewinder counts how often it is rewound, failing when it is a stream.
+type rewinder struct {
+	io.Reader
+	stream  bool
+	rewound int
+}
+
+func (r *rewinder) Rewind() error {
+	if r.stream {
+		return errors.New("can't rewind a stream")
+	}
+	r.rewound++
+	return nil
+}
+
+func TestEncodeRFC5987(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "report.pdf", want: "report.pdf"},
+		{in: "a-b_c~d!#$&+^`|", want: "a-b_c~d!#$&+^`|"},
+		{in: "two words.txt", want: "two%20words.txt"},
+		{in: "résumé.txt", want: "r%C3%A9sum%C3%A9.txt"},
+		{in: "日本.log", want: "%E6%97%A5%E6%9C%AC.log"},
+		{in: `"quoted";name=x`, want: "%22quoted%22%3Bname%3Dx"},
+		{in: "100%.txt", want: "100%25.txt"},
+		{in: "a'b*c(d)", want: "a%27b%2Ac%28d%29"},
+		{in: "line\nbreak", want: "line%0Abreak"},
+		{in: "", want: ""},
+	}
+	for _, test := range tests {
+		if got := encodeRFC5987(test.in); got != test.want {
+			t.Errorf("encodeRFC5987(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestAttachFileGetBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		r           io.Reader
+		wantGetBody bool
+	}{
+		{name: "seeker", r: strings.NewReader("data"), wantGetBody: true},
+		{name: "rewinder", r: &rewinder{Reader: strings.NewReader("data")}, wantGetBody: true},
+		{name: "rewinder over a stream", r: &rewinder{Reader: strings.NewReader("data"), stream: true}},
+		{name: "stream", r: unseekable{strings.NewReader("data")}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, d := newFakeClient(fakeResponse{status: 200, body: "[]"})
+			if _, err := c.AttachFile(context.Background(), "PROJ-1", "f.bin", test.r, 4); err != nil {
+				t.Fatalf("AttachFile: %v", err)
+			}
+			if got := d.requests[0].GetBody != nil; got != test.wantGetBody {
+				t.Errorf("GetBody set = %v, want %v", got, test.wantGetBody)
+			}
+		})
+	}
+}
+
+func TestRewind(t *testing.T) {
+	seeker := strings.NewReader("0123456789")
+	seeker.Seek(6, io.SeekStart)
+	tests := []struct {
+		name    string
+		r       io.Reader
+		read    int64
+		wantPos int64
+		wantErr bool
+	}{
+		{name: "seeker", r: seeker, read: 4, wantPos: 2},
+		{name: "unread seeker", r: strings.NewReader("abc"), read: 0, wantPos: 0},
+		{name: "rewinder", r: &rewinder{Reader: strings.NewReader("abc")}, read: 3},
+		{name: "stream", r: unseekable{strings.NewReader("abc")}, wantErr: true},
+		{name: "seeking before the start", r: strings.NewReader("abc"), read: 1, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := Rewind(test.r, test.read)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Rewind error = %v, want error %v", err, test.wantErr)
+			}
+			switch r := test.r.(type) {
+			case *rewinder:
+				if r.rewound != 1 {
+					t.Errorf("rewound %d times, want once", r.rewound)
+				}
+			case io.Seeker:
+				if pos, _ := r.Seek(0, io.SeekCurrent); !test.wantErr && pos != test.wantPos {
+					t.Errorf("position = %d, want %d", pos, test.wantPos)
+				}
+			}
+		})
+	}
+}
+
+func TestAddComment(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *CommentOptions
+		want string
+	}{
+		{name: "plain", want: `{"body":"Build log: [^build.log]"}`},
+		{name: "empty options", opts: &CommentOptions{}, want: `{"body":"Build log: [^build.log]"}`},
+		{
+			name: "visibility",
+			opts: &CommentOptions{Visibility: &Visibility{Type: "role", Value: "Developers"}},
+			want: `{"body":"Build log: [^build.log]","visibility":{"type":"role","value":"Developers"}}`,
+		},
+		{
+			name: "properties",
+			opts: &CommentOptions{Properties: []EntityProperty{{Key: "sd.public.comment", Value: map[string]bool{"internal": true}}}},
+			want: `{"body":"Build log: [^build.log]","properties":[{"key":"sd.public.comment","value":{"internal":true}}]}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, d := newFakeClient(fakeResponse{status: 201, body: `{"id": "100", "body": "Build log: [^build.log]"}`})
+			comment, err := c.AddComment(context.Background(), "PROJ-1", "Build log: [^build.log]", test.opts)
+			if err != nil {
+				t.Fatalf("AddComment: %v", err)
+			}
+			if comment.ID != "100" {
+				t.Errorf("comment ID = %q, want 100", comment.ID)
+			}
+			req := d.requests[0]
+			if req.Method != "POST" || req.URL.Path != "/rest/api/2/issue/PROJ-1/comment" {
+				t.Errorf("request = %v %v, want POST to the issue's comments", req.Method, req.URL.Path)
+			}
+			if got := req.Header.Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			if d.bodies[0] != test.want {
+				t.Errorf("body = %s, want %s", d.bodies[0], test.want)
+			}
+		})
+	}
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name        string
+		responses   []fakeResponse
+		call        func(c *Client) (int, error)
+		want        int
+		wantQueries []string
+		wantErr     bool
+	}{
+		{
+			name: "comments on one page",
+			responses: []fakeResponse{
+				{status: 200, body: `{"total": 2, "comments": [{"id": "1"}, {"id": "2"}]}`},
+			},
+			call: func(c *Client) (int, error) {
+				comments, err := c.Comments(context.Background(), "PROJ-1")
+				return len(comments), err
+			},
+			want:        2,
+			wantQueries: []string{"startAt=0"},
+		},
+		{
+			name: "comments on several pages",
+			responses: []fakeResponse{
+				{status: 200, body: `{"total": 3, "comments": [{"id": "1"}, {"id": "2"}]}`},
+				{status: 200, body: `{"total": 3, "comments": [{"id": "3"}]}`},
+			},
+			call: func(c *Client) (int, error) {
+				comments, err := c.Comments(context.Background(), "PROJ-1")
+				return len(comments), err
+			},
+			want:        3,
+			wantQueries: []string{"startAt=0", "startAt=2"},
+		},
+		{
+			name: "an empty page ends a short result",
+			responses: []fakeResponse{
+				{status: 200, body: `{"total": 5, "comments": [{"id": "1"}]}`},
+				{status: 200, body: `{"total": 5, "comments": []}`},
+			},
+			call: func(c *Client) (int, error) {
+				comments, err := c.Comments(context.Background(), "PROJ-1")
+				return len(comments), err
+			},
+			want:        1,
+			wantQueries: []string{"startAt=0", "startAt=1"},
+		},
+		{
+			name: "search on several pages",
+			responses: []fakeResponse{
+				{status: 200, body: `{"total": 2, "issues": [{"key": "PROJ-1"}]}`},
+				{status: 200, body: `{"total": 2, "issues": [{"key": "PROJ-2"}]}`},
+			},
+			call: func(c *Client) (int, error) {
+				issues, err := c.Search(context.Background(), "project = PROJ", "attachment")
+				return len(issues), err
+			},
+			want: 2,
+			wantQueries: []string{
+				"fields=attachment&jql=project+%3D+PROJ&maxResults=100&startAt=0",
+				"fields=attachment&jql=project+%3D+PROJ&maxResults=100&startAt=1",
+			},
+		},
+		{
+			name: "a failing page fails the search",
+			responses: []fakeResponse{
+				{status: 200, body: `{"total": 2, "issues": [{"key": "PROJ-1"}]}`},
+				{status: 500},
+			},
+			call: func(c *Client) (int, error) {
+				issues, err := c.Search(context.Background(), "project = PROJ")
+				return len(issues), err
+			},
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, d := newFakeClient(test.responses...)
+			got, err := test.call(c)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("got %d results, want %d", got, test.want)
+			}
+			var queries []string
+			for _, req := range d.requests {
+				queries = append(queries, req.URL.RawQuery)
+			}
+			if !reflect.DeepEqual(queries, test.wantQueries) {
+				t.Errorf("queries = %q, want %q", queries, test.wantQueries)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package jira
+
+import (
+	"strings"
+	"time"
+)
+
+// TimeLayout is the timestamp format used throughout the Jira REST API.
+const TimeLayout = "2006-01-02T15:04:05.000-0700"
+
+// Time is a time.Time that understands Jira's timestamp format.
+type Time struct {
+	time.Time
+}
+
+func (t *Time) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		return nil
+	}
+	parsed, err := time.Parse(TimeLayout, s)
+	if err != nil {
+		return err
+	}
+	t.Time = parsed
+	return nil
+}
+
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return []byte(`"` + t.Format(TimeLayout) + `"`), nil
+}
+
+type User struct {
+	Name         string `json:"name,omitempty"`
+	AccountID    string `json:"accountId,omitempty"`
+	DisplayName  string `json:"displayName"`
+	EmailAddress string `json:"emailAddress,omitempty"`
+}
+
+type Attachment struct {
+	ID        string `json:"id"`
+	Self      string `json:"self"`
+	Filename  string `json:"filename"`
+	Author    User   `json:"author"`
+	Created   Time   `json:"created"`
+	Size      int64  `json:"size"`
+	MimeType  string `json:"mimeType"`
+	Content   string `json:"content"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+
+	// ScanStatus is reported by Data Center instances that scan
+	// attachments for malware.
+	ScanStatus string `json:"scanStatus,omitempty"`
+}
+
+type Comment struct {
+	ID      string `json:"id"`
+	Author  User   `json:"author"`
+	Body    string `json:"body"`
+	Created Time   `json:"created"`
+	Updated Time   `json:"updated"`
+}
+
+type Issue struct {
+	Key    string      `json:"key"`
+	Fields IssueFields `json:"fields"`
+}
+
+type IssueFields struct {
+	Summary     string       `json:"summary"`
+	Description string       `json:"description"`
+	Status      *Status      `json:"status,omitempty"`
+	Assignee    *User        `json:"assignee,omitempty"`
+	Reporter    *User        `json:"reporter,omitempty"`
+	Attachments []Attachment `json:"attachment"`
+}
+
+type Status struct {
+	Name     string          `json:"name"`
+	Category *StatusCategory `json:"statusCategory,omitempty"`
+}
+
+// StatusCategory groups statuses into new, indeterminate (in progress) and
+// done.
+type StatusCategory struct {
+	Key string `json:"key"`
+}
@@ -0,0 +1,132 @@
+package jiraattach
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// junitSuite is a JUnit XML <testsuite>. Reports may nest suites inside a
+// <testsuites> root or inside each other.
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	Time   float64      `xml:"time,attr"`
+	Suites []junitSuite `xml:"testsuite"`
+	Cases  []junitCase  `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *junitProblem `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitTotals summarizes the test cases of a report.
+type junitTotals struct {
+	tests, failures, errors, skipped int
+	time                             float64
+	failed                           []junitCase
+}
+
+func (t *junitTotals) add(s junitSuite) {
+	t.time += s.Time
+	for _, c := range s.Cases {
+		t.tests++
+		switch {
+		case c.Failure != nil:
+			t.failures++
+			t.failed = append(t.failed, c)
+		case c.Error != nil:
+			t.errors++
+			t.failed = append(t.failed, c)
+		case c.Skipped != nil:
+			t.skipped++
+		}
+	}
+	for _, nested := range s.Suites {
+		// Nested suites report their own time, which is included in ours.
+		nested.Time = 0
+		t.add(nested)
+	}
+}
+
+// readJUnit reads the JUnit XML report at path and totals its test cases.
+func readJUnit(path string) (*junitTotals, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JUnit report: %w", err)
+	}
+	var root struct {
+		XMLName xml.Name
+		junitSuite
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("error reading JUnit report, %v: %w", path, err)
+	}
+	totals := &junitTotals{}
+	switch root.XMLName.Local {
+	case "testsuites":
+		for _, s := range root.Suites {
+			totals.add(s)
+		}
+	case "testsuite":
+		totals.add(root.junitSuite)
+	default:
+		return nil, fmt.Errorf("error reading JUnit report, %v: unexpected root element <%v>", path, root.XMLName.Local)
+	}
+	return totals, nil
+}
+
+// comment returns a wiki markup comment summarizing the totals and the first
+// n failing tests, linking to the report attached as filename.
+func (totals *junitTotals) comment(filename string, n int) string {
+	buf := &bytes.Buffer{}
+	status := "(/) passed"
+	if len(totals.failed) > 0 {
+		status = "(x) failed"
+	}
+	fmt.Fprintf(buf, "Test run %v: *%d tests*, %d failures, %d errors, %d skipped in %.1fs. Full report: [^%v]\n",
+		status, totals.tests, totals.failures, totals.errors, totals.skipped, totals.time, filename)
+	for i, c := range totals.failed {
+		if i == n {
+			fmt.Fprintf(buf, "* ...and %d more\n", len(totals.failed)-n)
+			break
+		}
+		problem := c.Failure
+		if problem == nil {
+			problem = c.Error
+		}
+		message := problem.Message
+		if message == "" {
+			message = problem.Text
+		}
+		name := c.Name
+		if c.ClassName != "" {
+			name = c.ClassName + "." + c.Name
+		}
+		fmt.Fprintf(buf, "* {{%v}}: %v\n", name, summarizeMessage(message))
+	}
+	return buf.String()
+}
+
+// summarizeMessage reduces a failure message to the start of its first line
+// so it fits on a single list item.
+func summarizeMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		message = message[:i]
+	}
+	if len(message) > 200 {
+		message = message[:200] + "..."
+	}
+	return message
+}
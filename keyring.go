@@ -0,0 +1,80 @@
+package jiraattach
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// keyringService names jiraattach's entries in the OS keyring. Each entry's
+// account is the Jira URL it holds the credentials for.
+const keyringService = "jiraattach"
+
+// errNotInKeyring is returned when the keyring has no entry for a site.
+var errNotInKeyring = errors.New("no credentials in the keyring")
+
+// keyringGet returns the auth setting saved by login for the site at
+// jiraURL.
+func keyringGet(jiraURL string) (string, error) {
+	auth, err := keyringRead(siteKey(jiraURL))
+	if keyringMissing(err) {
+		// Without the keyring tool nothing can have been saved in it.
+		return "", errNotInKeyring
+	}
+	if err != nil && err != errNotInKeyring {
+		return "", fmt.Errorf("error reading the keyring: %w", err)
+	}
+	return auth, err
+}
+
+// keyringMissing reports whether err is the failure to find the keyring
+// tool, as on headless machines without one.
+func keyringMissing(err error) bool {
+	var e *exec.Error
+	return errors.As(err, &e) && e.Err == exec.ErrNotFound
+}
+
+// keyringSet saves auth for the site at jiraURL, replacing any earlier
+// entry.
+func keyringSet(jiraURL, auth string) error {
+	if err := keyringWrite(siteKey(jiraURL), auth); err != nil {
+		return fmt.Errorf("error writing the keyring: %w", err)
+	}
+	return nil
+}
+
+// keyringDelete removes the entry for the site at jiraURL. Removing an
+// entry that doesn't exist returns errNotInKeyring.
+func keyringDelete(jiraURL string) error {
+	err := keyringRemove(siteKey(jiraURL))
+	if err != nil && err != errNotInKeyring {
+		return fmt.Errorf("error writing the keyring: %w", err)
+	}
+	return err
+}
+
+// loadKeyringAuth fills in the credentials of the top level settings and of
+// every profile that has a Jira URL but no auth from the keyring, so that
+// credentials saved by login don't need to be in the config file. Sites
+// logged in to with OAuth keep their tokens elsewhere and are skipped.
+func (c *Config) loadKeyringAuth() {
+	lookup := func(jiraURL, authType string) string {
+		if jiraURL == "" || authType == authOAuth {
+			return ""
+		}
+		auth, err := keyringGet(jiraURL)
+		if err != nil && err != errNotInKeyring {
+			warnf("%v", err)
+		}
+		return auth
+	}
+	if c.Auth == "" {
+		c.Auth = lookup(c.JiraURL, c.AuthType)
+	}
+	for name, p := range c.Profiles {
+		if p.Auth == "" && p.JiraURL != "" {
+			p.Auth = lookup(p.JiraURL, p.authType(c))
+			c.Profiles[name] = p
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// The macOS Keychain is reached through the security command.
+
+func keyringRead(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w").Output()
+	if err != nil {
+		if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 44 {
+			return "", errNotInKeyring
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+// keyringWrite runs add-generic-password in security's interactive mode,
+// reading the command from stdin, so that the secret never appears in the
+// process list as an argument would.
+func keyringWrite(account, secret string) error {
+	if strings.ContainsAny(secret, "\r\n") {
+		return fmt.Errorf("secret can't contain line breaks")
+	}
+	command := []string{"add-generic-password", "-U", "-s", keyringService, "-a", account, "-l", "jiraattach " + account, "-w", secret}
+	for i, arg := range command {
+		command[i] = securityQuote(arg)
+	}
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(strings.Join(command, " ") + "\n")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return err
+	}
+	// Interactive mode exits successfully whatever the command did, so
+	// failures are only reported in its output, between its prompts.
+	if msg := strings.TrimSpace(strings.Replace(string(out), "security>", "", -1)); msg != "" {
+		return fmt.Errorf("%v", msg)
+	}
+	return nil
+}
+
+// securityQuote quotes arg for a command line read by security -i.
+func securityQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+func keyringRemove(account string) error {
+	err := exec.Command("security", "delete-generic-password", "-s", keyringService, "-a", account).Run()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 44 {
+		return errNotInKeyring
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+//go:build !darwin && !windows
+// +build !darwin,!windows
+
+package jiraattach
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// Elsewhere the Secret Service, as provided by GNOME Keyring or KWallet, is
+// reached through secret-tool from libsecret.
+
+func keyringRead(account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", keyringService, "account", account).Output()
+	if exit, ok := err.(*exec.ExitError); ok && exit.ExitCode() == 1 && len(out) == 0 && len(exit.Stderr) == 0 {
+		return "", errNotInKeyring
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func keyringWrite(account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label=jiraattach "+account, "service", keyringService, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	return cmd.Run()
+}
+
+func keyringRemove(account string) error {
+	if _, err := keyringRead(account); err != nil {
+		return err
+	}
+	return exec.Command("secret-tool", "clear", "service", keyringService, "account", account).Run()
+}
@@ -0,0 +1,39 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const listUsage = `usage: jiraattach list key
+
+  List the attachments on a Jira Issue.
+`
+
+// runList implements the list command, printing the attachments on an issue.
+func runList(config *Config, args []string) error {
+	fs := newFlagSet("list", listUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+
+	attachments, err := config.client().attachments(key)
+	if err != nil {
+		return fmt.Errorf("error listing attachments on %v: %w", key, err)
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, a := range attachments {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\n", a.ID, a.Created.Format("2006-01-02 15:04"), a.Size, a.Filename)
+	}
+	return tw.Flush()
+}
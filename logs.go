@@ -0,0 +1,202 @@
+package jiraattach
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// syslogPaths are the syslog files read when journalctl isn't available.
+var syslogPaths = []string{"/var/log/syslog", "/var/log/messages"}
+
+const logsUsage = `usage: jiraattach logs [-since=time] [-until=time] [-unit=name]...
+  [-syslog=path] key
+
+  Attach a gzip compressed slice of the host's journal, or of its syslog
+  when journalctl isn't available, covering the last hour by default. Times
+  are relative like -1h or absolute like "2006-01-02 15:04:05".
+`
+
+// runLogs implements the logs command, attaching a compressed slice of the
+// host's journal or syslog.
+func runLogs(config *Config, args []string) error {
+	fs := newFlagSet("logs", logsUsage)
+	since := fs.String("since", "-1h", "start of the window, relative like -1h or absolute like 2006-01-02 15:04:05")
+	until := fs.String("until", "", "end of the window, defaults to now")
+	var units StringList
+	fs.Var(&units, "unit", "only include this systemd unit or syslog program, may be repeated")
+	syslog := fs.String("syslog", "", "read this syslog file instead of the journal")
+	allowsecrets := fs.Bool("allow-secrets", config.AllowSecrets, "attach logs even if they appear to contain secrets")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	config.AllowSecrets = *allowsecrets
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+
+	tmp, err := ioutil.TempFile("", "jiraattach-logs-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	zw := gzip.NewWriter(tmp)
+	if _, err := exec.LookPath("journalctl"); err == nil && *syslog == "" {
+		err = journal(zw, *since, *until, units)
+	} else {
+		err = syslogSlice(zw, *syslog, *since, *until, units)
+	}
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("error compressing logs: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading compressed logs: %w", err)
+	}
+
+	source, _ := os.Hostname()
+	if len(units) > 0 {
+		source = strings.Join(units, "+")
+	}
+	name := fmt.Sprintf("logs-%v-%v.log.gz", source, time.Now().UTC().Format("20060102T150405Z"))
+	_, err = attachFile(config, key, name, tmp)
+	return err
+}
+
+// journal writes the journal entries in the window to w.
+func journal(w io.Writer, since, until string, units []string) error {
+	args := []string{"--no-pager", "--output=short-iso", "--since=" + since}
+	if until != "" {
+		args = append(args, "--until="+until)
+	}
+	for _, unit := range units {
+		args = append(args, "--unit="+unit)
+	}
+	cmd := exec.Command("journalctl", args...)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error reading journal: %w", err)
+	}
+	return nil
+}
+
+// syslogSlice writes the lines of a syslog file that fall in the window,
+// and belong to one of the programs when any are given, to w.
+func syslogSlice(w io.Writer, path, since, until string, programs []string) error {
+	now := time.Now()
+	start, err := parseLogTime(since, now)
+	if err != nil {
+		return err
+	}
+	end := now
+	if until != "" {
+		if end, err = parseLogTime(until, now); err != nil {
+			return err
+		}
+	}
+
+	if path == "" {
+		for _, p := range syslogPaths {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+		if path == "" {
+			return fmt.Errorf("journalctl isn't available and no syslog file was found, use -syslog")
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error reading syslog: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		t, ok := syslogTime(line, now)
+		if !ok || t.Before(start) || t.After(end) {
+			continue
+		}
+		if len(programs) > 0 && !fromProgram(line, programs) {
+			continue
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return fmt.Errorf("error writing logs: %w", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading syslog: %w", err)
+	}
+	return nil
+}
+
+// parseLogTime parses a time relative to now, such as -1h or -2d, or an
+// absolute local time.
+func parseLogTime(s string, now time.Time) (time.Time, error) {
+	if strings.HasPrefix(s, "-") {
+		d, err := parseAge(s[1:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// syslogTime parses the timestamp at the start of a syslog line, either
+// RFC 3339 or the traditional "Jan _2 15:04:05" which has no year.
+func syslogTime(line string, now time.Time) (time.Time, bool) {
+	if i := strings.IndexByte(line, ' '); i > 0 {
+		if t, err := time.Parse(time.RFC3339Nano, line[:i]); err == nil {
+			return t, true
+		}
+	}
+	if len(line) < 15 {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(time.Stamp, line[:15], time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0) // the line is from last year
+	}
+	return t, true
+}
+
+// fromProgram reports whether the syslog line was logged by one of the
+// programs, which appear as "program:" or "program[pid]:".
+func fromProgram(line string, programs []string) bool {
+	for _, p := range programs {
+		p = strings.TrimSuffix(p, ".service")
+		if strings.Contains(line, " "+p+":") || strings.Contains(line, " "+p+"[") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// sanitizeName makes name, the base name of a local file, safe to show in
+// Jira by replacing control characters and path separators.
+func sanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f || r == '/' || r == '\\' {
+			return '_'
+		}
+		return r
+	}, name)
+	return strings.TrimSpace(name)
+}
+
+// checkName checks that name, given for an attachment, is a filename rather
+// than a path.
+func checkName(name string) error {
+	if strings.TrimSpace(name) == "" || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("%q is not a filename", name)
+	}
+	return nil
+}
+
+// parseNameTemplate parses a -name-template such as
+// "{{date}}-{{hostname}}-{{basename}}". The functions it may use are bound to
+// a particular file by renderName.
+func parseNameTemplate(text string) (*template.Template, error) {
+	t, err := template.New("name").Funcs(nameFuncs("", "", time.Time{})).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing name template: %w", err)
+	}
+	return t, nil
+}
+
+// nameFuncs returns the functions available to name templates for a file
+// named name uploaded to key at now.
+func nameFuncs(key, name string, now time.Time) template.FuncMap {
+	base := filepath.Base(name)
+	ext := filepath.Ext(base)
+	return template.FuncMap{
+		"date":     func() string { return now.Format("2006-01-02") },
+		"time":     func() string { return now.Format("150405") },
+		"hostname": func() string { h, _ := os.Hostname(); return h },
+		"user": func() string {
+			if u, err := user.Current(); err == nil {
+				return u.Username
+			}
+			return ""
+		},
+		"issue":    func() string { return key },
+		"basename": func() string { return base },
+		"stem":     func() string { return strings.TrimSuffix(base, ext) },
+		"ext":      func() string { return ext },
+	}
+}
+
+// renderName returns the filename t gives the file named name when it is
+// uploaded to key.
+func renderName(t *template.Template, key, name string) (string, error) {
+	var buf bytes.Buffer
+	t = template.Must(t.Clone()).Funcs(nameFuncs(key, name, time.Now()))
+	if err := t.Execute(&buf, nil); err != nil {
+		return "", fmt.Errorf("error rendering name template for %v: %w", name, err)
+	}
+	rendered := strings.TrimSpace(buf.String())
+	if rendered == "" || strings.ContainsAny(rendered, `/\`) {
+		return "", fmt.Errorf("name template gives %v the invalid filename %q", name, rendered)
+	}
+	return rendered, nil
+}
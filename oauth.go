@@ -0,0 +1,400 @@
+package jiraattach
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Atlassian OAuth 2.0 (3LO) endpoints.
+var (
+	oauthAuthorizeURL = "https://auth.atlassian.com/authorize"
+	oauthTokenURL     = "https://auth.atlassian.com/oauth/token"
+	oauthResourcesURL = "https://api.atlassian.com/oauth/token/accessible-resources"
+	oauthAPIURL       = "https://api.atlassian.com/ex/jira/"
+)
+
+// oauthFile is the state file holding OAuth tokens, keyed by site URL, on
+// machines without a keyring.
+const oauthFile = "oauth.json"
+
+// oauthKeyringPrefix sets the keyring entries holding a site's OAuth
+// tokens apart from the credentials login saves for other auth types.
+const oauthKeyringPrefix = "oauth:"
+
+// defaultOAuthScopes lets jiraattach read issues, attach files and comment,
+// and offline_access provides a refresh token so logins last.
+var defaultOAuthScopes = []string{"read:jira-work", "write:jira-work", "read:jira-user", "offline_access"}
+
+// OAuthConfig identifies the OAuth 2.0 app, created in the Atlassian
+// developer console, that login authorizes jiraattach as.
+type OAuthConfig struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	CallbackPort int      `json:"callback_port"`
+	Scopes       []string `json:"scopes"`
+}
+
+func (o OAuthConfig) redirectURI() string {
+	port := o.CallbackPort
+	if port == 0 {
+		port = 8765
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d/callback", port)
+}
+
+func (o OAuthConfig) scopes() []string {
+	if len(o.Scopes) > 0 {
+		return o.Scopes
+	}
+	return defaultOAuthScopes
+}
+
+// oauthToken is the result of logging in to a site.
+type oauthToken struct {
+	CloudID      string    `json:"cloud_id"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// siteKey normalizes a Jira URL for looking up the credentials saved for it.
+func siteKey(jiraURL string) string {
+	return strings.TrimSuffix(strings.ToLower(jiraURL), "/")
+}
+
+// loadOAuthToken returns the tokens saved by login for site, from the
+// keyring or, on machines without one, the state directory. It returns nil
+// when the site hasn't been logged in to.
+func loadOAuthToken(site string) (*oauthToken, error) {
+	saved, err := keyringGet(oauthKeyringPrefix + site)
+	if err == nil {
+		token := &oauthToken{}
+		if err := json.Unmarshal([]byte(saved), token); err != nil {
+			return nil, fmt.Errorf("error reading OAuth tokens from the keyring: %w", err)
+		}
+		return token, nil
+	}
+	if err != errNotInKeyring {
+		warnf("%v", err)
+	}
+	tokens := map[string]*oauthToken{}
+	if err := loadState(oauthFile, &tokens); err != nil {
+		return nil, fmt.Errorf("error reading OAuth tokens: %w", err)
+	}
+	return tokens[siteKey(site)], nil
+}
+
+// saveOAuthToken saves the tokens for site in the keyring, or removes them
+// when token is nil. Machines without a keyring keep them in the state
+// directory instead, readable only by the user.
+func saveOAuthToken(site string, token *oauthToken) error {
+	if token == nil {
+		if err := keyringDelete(oauthKeyringPrefix + site); err != nil && err != errNotInKeyring && !keyringMissing(err) {
+			return err
+		}
+		return saveOAuthFile(site, nil)
+	}
+	b, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("error encoding OAuth tokens: %w", err)
+	}
+	err = keyringSet(oauthKeyringPrefix+site, string(b))
+	if err == nil {
+		// Drop any copy saved before the keyring was available.
+		return saveOAuthFile(site, nil)
+	}
+	if !keyringMissing(err) {
+		warnf("%v; saving the OAuth tokens in the state directory instead", err)
+	}
+	return saveOAuthFile(site, token)
+}
+
+// saveOAuthFile saves the tokens for site in the state directory, or
+// removes them when token is nil.
+func saveOAuthFile(site string, token *oauthToken) error {
+	tokens := map[string]*oauthToken{}
+	if err := loadState(oauthFile, &tokens); err != nil {
+		return fmt.Errorf("error reading OAuth tokens: %w", err)
+	}
+	if _, ok := tokens[siteKey(site)]; !ok && token == nil {
+		return nil
+	}
+	if token == nil {
+		delete(tokens, siteKey(site))
+	} else {
+		tokens[siteKey(site)] = token
+	}
+	if err := saveState(oauthFile, tokens); err != nil {
+		return fmt.Errorf("error saving OAuth tokens: %w", err)
+	}
+	return nil
+}
+
+// oauthSession supplies access tokens for a site logged in to with login,
+// refreshing them as they expire.
+type oauthSession struct {
+	mu    sync.Mutex
+	site  string
+	conf  OAuthConfig
+	token *oauthToken
+}
+
+// accessToken returns a current access token, refreshing the stored one
+// when it is about to expire.
+func (s *oauthSession) accessToken(ctx context.Context, hc *http.Client, agent string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == nil {
+		return "", fmt.Errorf("not logged in to %v, run jiraattach login", s.site)
+	}
+	if time.Until(s.token.Expiry) > time.Minute {
+		return s.token.AccessToken, nil
+	}
+	if s.token.RefreshToken == "" {
+		return "", fmt.Errorf("login to %v has expired, run jiraattach login", s.site)
+	}
+	refreshed, err := requestToken(ctx, hc, agent, map[string]string{
+		"grant_type":    "refresh_token",
+		"client_id":     s.conf.ClientID,
+		"client_secret": s.conf.ClientSecret,
+		"refresh_token": s.token.RefreshToken,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error refreshing login to %v, run jiraattach login: %w", s.site, err)
+	}
+	refreshed.CloudID = s.token.CloudID
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = s.token.RefreshToken
+	}
+	s.token = refreshed
+	if err := saveOAuthToken(s.site, refreshed); err != nil {
+		warnf("%v", err)
+	}
+	return refreshed.AccessToken, nil
+}
+
+// requestToken posts a token request and returns the token it grants.
+func requestToken(ctx context.Context, hc *http.Client, agent string, params map[string]string) (*oauthToken, error) {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding token request: %w", err)
+	}
+	req, err := http.NewRequest("POST", oauthTokenURL, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", agent)
+	var grant struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := doJSON(hc, req, &grant); err != nil {
+		return nil, err
+	}
+	addCredential(grant.AccessToken)
+	addCredential(grant.RefreshToken)
+	return &oauthToken{
+		AccessToken:  grant.AccessToken,
+		RefreshToken: grant.RefreshToken,
+		Expiry:       time.Now().Add(time.Duration(grant.ExpiresIn) * time.Second),
+	}, nil
+}
+
+// doJSON sends a request outside the Jira API and decodes its JSON response.
+func doJSON(hc *http.Client, req *http.Request, v interface{}) error {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return &sendError{Err: err}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &statusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error decoding response: %w", err)
+	}
+	return nil
+}
+
+// cloudResource is a site the authorized user granted access to.
+type cloudResource struct {
+	ID   string `json:"id"`
+	URL  string `json:"url"`
+	Name string `json:"name"`
+}
+
+// findCloudID returns the cloud ID of the site at jiraURL among those the
+// token can access.
+func findCloudID(ctx context.Context, hc *http.Client, agent, accessToken, jiraURL string) (string, error) {
+	req, err := http.NewRequest("GET", oauthResourcesURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("User-Agent", agent)
+	var resources []cloudResource
+	if err := doJSON(hc, req, &resources); err != nil {
+		return "", fmt.Errorf("error listing accessible sites: %w", err)
+	}
+	var sites []string
+	for _, r := range resources {
+		if siteKey(r.URL) == siteKey(jiraURL) {
+			return r.ID, nil
+		}
+		sites = append(sites, r.URL)
+	}
+	if len(sites) == 0 {
+		return "", fmt.Errorf("the login doesn't grant access to any Jira site")
+	}
+	return "", fmt.Errorf("the login doesn't grant access to %v, only to %v", jiraURL, strings.Join(sites, ", "))
+}
+
+// oauthLogin authorizes jiraattach with the Atlassian OAuth 2.0
+// three-legged flow. A browser is opened on the consent page, which
+// redirects back to a server listening on 127.0.0.1 with the authorization
+// code.
+func oauthLogin(config *Config, nobrowser bool) error {
+	conf := config.OAuth
+	if conf.ClientID == "" || conf.ClientSecret == "" {
+		return fmt.Errorf("oauth client_id and client_secret must be set to log in")
+	}
+	addCredential(conf.ClientSecret)
+
+	redirect := conf.redirectURI()
+	u, _ := url.Parse(redirect)
+	// The callback is received on the very address registered as its
+	// URL, where browsers send it.
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return fmt.Errorf("error listening for the login callback: %w", err)
+	}
+	defer listener.Close()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("error generating login state: %w", err)
+	}
+	state := hex.EncodeToString(b)
+	q := url.Values{}
+	q.Set("audience", "api.atlassian.com")
+	q.Set("client_id", conf.ClientID)
+	q.Set("scope", strings.Join(conf.scopes(), " "))
+	q.Set("redirect_uri", redirect)
+	q.Set("state", state)
+	q.Set("response_type", "code")
+	q.Set("prompt", "consent")
+	authorize := oauthAuthorizeURL + "?" + q.Encode()
+
+	// Only the first callback counts; later ones, such as a reloaded page,
+	// mustn't block the server.
+	codes := make(chan string, 1)
+	errs := make(chan error, 1)
+	report := func(code string, err error) {
+		if err != nil {
+			select {
+			case errs <- err:
+			default:
+			}
+			return
+		}
+		select {
+		case codes <- code:
+		default:
+		}
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != u.Path {
+			http.NotFound(w, r)
+			return
+		}
+		switch {
+		case r.FormValue("state") != state:
+			http.Error(w, "Login failed: the response doesn't match this login.", http.StatusBadRequest)
+			report("", fmt.Errorf("login callback state doesn't match"))
+		case r.FormValue("error") != "":
+			http.Error(w, "Login failed: "+r.FormValue("error_description"), http.StatusBadRequest)
+			report("", fmt.Errorf("login failed: %v %v", r.FormValue("error"), r.FormValue("error_description")))
+		default:
+			fmt.Fprintln(w, "Logged in to jiraattach. You can close this window.")
+			report(r.FormValue("code"), nil)
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	if nobrowser || openBrowser(authorize) != nil {
+		fmt.Fprintf(os.Stderr, "Open this URL in a browser to log in:\n\n  %v\n\n", authorize)
+	} else {
+		fmt.Fprintln(os.Stderr, "Waiting for the login to finish in the browser...")
+	}
+
+	var code string
+	select {
+	case code = <-codes:
+	case err := <-errs:
+		return err
+	case <-time.After(5 * time.Minute):
+		return fmt.Errorf("timed out waiting for the login to finish")
+	case <-config.settings.context().Done():
+		return config.settings.context().Err()
+	}
+
+	c := config.client()
+	token, err := requestToken(c.context(), c.http, c.agent, map[string]string{
+		"grant_type":    "authorization_code",
+		"client_id":     conf.ClientID,
+		"client_secret": conf.ClientSecret,
+		"code":          code,
+		"redirect_uri":  redirect,
+	})
+	if err != nil {
+		return fmt.Errorf("error exchanging the authorization code: %w", err)
+	}
+	if token.CloudID, err = findCloudID(c.context(), c.http, c.agent, token.AccessToken, config.JiraURL); err != nil {
+		return err
+	}
+	if err := saveOAuthToken(config.JiraURL, token); err != nil {
+		return err
+	}
+	// Clients created before the login still have the old token.
+	config.c = nil
+	config.profileClients = nil
+	fmt.Printf("Logged in to %v\n", config.JiraURL)
+	return nil
+}
+
+// openBrowser opens u in the user's default browser.
+func openBrowser(u string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", u)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", u)
+	default:
+		cmd = exec.Command("xdg-open", u)
+	}
+	return cmd.Start()
+}
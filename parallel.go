@@ -0,0 +1,19 @@
+package jiraattach
+
+// concurrency returns the number of files to upload at once, 1 unless
+// concurrency is configured.
+func (c *Config) concurrency() int {
+	if c.Concurrency < 1 {
+		return 1
+	}
+	return c.Concurrency
+}
+
+// routeAll routes each of keys, creating the clients they use. Routing
+// caches what it creates, so parallel uploads must have their keys routed
+// up front, after which route is safe to call from each of them.
+func (c *Config) routeAll(keys []string) {
+	for _, key := range keys {
+		c.route(key).client()
+	}
+}
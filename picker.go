@@ -0,0 +1,110 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// pickerRows is the number of issues the picker lists at a time.
+const pickerRows = 10
+
+// pickRecentIssue lets the user choose one of the issues recently uploaded
+// to by typing any part of its key or of a filename uploaded to it, then the
+// number of the issue.
+func pickRecentIssue() (string, error) {
+	recent, err := recentIssues()
+	if err != nil {
+		return "", fmt.Errorf("error reading upload history: %w", err)
+	}
+	if len(recent) == 0 {
+		return "", fmt.Errorf("no recent uploads to choose from")
+	}
+
+	lr := &plainReader{out: os.Stderr}
+	matches := recent
+	for {
+		for i, e := range matches {
+			if i == pickerRows {
+				fmt.Fprintf(os.Stderr, "     ... %d more\n", len(matches)-pickerRows)
+				break
+			}
+			fmt.Fprintf(os.Stderr, "%3d  %-12v %v  %v\n", i+1, e.Issue, e.Time.Format("2006-01-02 15:04"), e.Filename)
+		}
+		line, err := lr.readLine("Issue number, or text to search for: ")
+		if err != nil {
+			return "", fmt.Errorf("no issue chosen")
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && len(matches) > 0 {
+			return matches[0].Issue, nil
+		}
+		if n, err := strconv.Atoi(line); err == nil && n >= 1 && n <= len(matches) && n <= pickerRows {
+			return matches[n-1].Issue, nil
+		}
+		matches = fuzzyFilter(recent, line)
+		if len(matches) == 0 {
+			fmt.Fprintf(os.Stderr, "no issues match %q\n", line)
+			matches = recent
+		}
+	}
+}
+
+// fuzzyFilter returns the entries whose issue key and filename contain the
+// letters of query in order, best matches first. A match is better the
+// closer together its letters are; ties keep the most recent first.
+func fuzzyFilter(entries []historyEntry, query string) []historyEntry {
+	type scored struct {
+		entry historyEntry
+		score int
+	}
+	var matches []scored
+	for _, e := range entries {
+		if score, ok := fuzzyMatch(e.Issue+" "+e.Filename, query); ok {
+			matches = append(matches, scored{e, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+	filtered := make([]historyEntry, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.entry
+	}
+	return filtered
+}
+
+// fuzzyMatch reports whether the runes of query appear in s in order,
+// ignoring case and spaces, and scores the match by the length of the
+// shortest span of s containing them.
+func fuzzyMatch(s, query string) (int, bool) {
+	text := []rune(strings.ToLower(s))
+	var pattern []rune
+	for _, r := range strings.ToLower(query) {
+		if !unicode.IsSpace(r) {
+			pattern = append(pattern, r)
+		}
+	}
+	if len(pattern) == 0 {
+		return 0, true
+	}
+	best, found := 0, false
+	for start := range text {
+		if text[start] != pattern[0] {
+			continue
+		}
+		i, end := 0, start
+		for ; end < len(text) && i < len(pattern); end++ {
+			if text[end] == pattern[i] {
+				i++
+			}
+		}
+		if i == len(pattern) && (!found || end-start < best) {
+			best, found = end-start, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,154 @@
+package jiraattach
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Profile holds the location and credentials of a Jira instance other than
+// the default one. Empty fields fall back to the top level settings, except
+// that credentials and headers are only shared with a profile for the same
+// site, so one site's token is never sent to another.
+type Profile struct {
+	JiraURL  string            `json:"jira_url"`
+	Auth     string            `json:"auth"`
+	AuthType string            `json:"auth_type"`
+	Headers  map[string]string `json:"headers"`
+}
+
+// sameSite reports whether the profile is for the same site as c, so that
+// it may use c's credentials.
+func (p Profile) sameSite(c *Config) bool {
+	if p.JiraURL == "" {
+		return true
+	}
+	u, err := url.Parse(p.JiraURL)
+	if err != nil {
+		return false
+	}
+	top, err := url.Parse(c.JiraURL)
+	return err == nil && strings.EqualFold(u.Host, top.Host)
+}
+
+// auth returns the profile's credentials, or those of c when it has none
+// and is for the same site.
+func (p Profile) auth(c *Config) string {
+	if p.Auth != "" || !p.sameSite(c) {
+		return p.Auth
+	}
+	return c.Auth
+}
+
+// authType returns the profile's auth_type, or that of c when it has none.
+func (p Profile) authType(c *Config) string {
+	if p.AuthType != "" {
+		return p.AuthType
+	}
+	return c.AuthType
+}
+
+// headers returns the profile's extra headers, or those of c when it has
+// none and is for the same site.
+func (p Profile) headers(c *Config) map[string]string {
+	if p.Headers != nil || !p.sameSite(c) {
+		return p.Headers
+	}
+	return c.Headers
+}
+
+// checkProfile checks that the named profile has credentials of its own
+// when it is for a different site than the top level settings.
+func (c *Config) checkProfile(name string) error {
+	p := c.Profiles[name]
+	if p.Auth == "" && !p.sameSite(c) && p.authType(c) != authOAuth {
+		return fmt.Errorf("profile %q has no credentials for %v; set its auth, or run login, rather than sharing those of %v", name, p.JiraURL, c.JiraURL)
+	}
+	return nil
+}
+
+// useProfile makes the named profile the instance every command in the run
+// uses, whatever the issue key, as selected with -profile.
+func (c *Config) useProfile(name string) error {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	if err := c.checkProfile(name); err != nil {
+		return err
+	}
+	if profile.JiraURL != "" {
+		c.JiraURL = profile.JiraURL
+	}
+	c.Auth, c.AuthType = profile.auth(c), profile.authType(c)
+	c.Headers = profile.headers(c)
+	c.Routes = nil
+	return nil
+}
+
+// validateRoutes checks that every route is a valid pattern naming a
+// profile that exists.
+func (c *Config) validateRoutes() error {
+	for pattern, name := range c.Routes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid route %q: %w", pattern, err)
+		}
+		if _, ok := c.Profiles[name]; !ok {
+			return fmt.Errorf("route %q refers to unknown profile %q", pattern, name)
+		}
+		if err := c.checkProfile(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// routeProfile returns the name of the profile routed to for key, or "" when
+// no route matches. When several routes match the longest pattern wins.
+func (c *Config) routeProfile(key string) string {
+	var patterns []string
+	for pattern := range c.Routes {
+		if ok, _ := path.Match(pattern, key); ok {
+			patterns = append(patterns, pattern)
+		}
+	}
+	if len(patterns) == 0 {
+		return ""
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	return c.Routes[patterns[0]]
+}
+
+// route returns the config to use for the issue identified by key. When a
+// route matches the key this is a copy of c using the routed profile's
+// instance and credentials, otherwise it is c itself. Each profile's client
+// is shared for the life of c, as the default client is.
+func (c *Config) route(key string) *Config {
+	name := c.routeProfile(key)
+	if name == "" {
+		return c
+	}
+	routed := *c
+	profile := c.Profiles[name]
+	if profile.JiraURL != "" {
+		routed.JiraURL = profile.JiraURL
+	}
+	routed.Auth = profile.auth(c)
+	routed.AuthType = profile.authType(c)
+	routed.Headers = profile.headers(c)
+	if c.profileClients == nil {
+		c.profileClients = map[string]*client{}
+	}
+	if c.profileClients[name] == nil {
+		c.profileClients[name] = newClient(&routed)
+	}
+	routed.c = c.profileClients[name]
+	return &routed
+}
@@ -0,0 +1,295 @@
+package jiraattach
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+// progress tracks the uploads in flight so that they can be reported on
+// demand without killing long running jobs. Parallel uploads are reported
+// together.
+type progress struct {
+	mu        sync.Mutex
+	transfers []*transfer
+}
+
+// transfer is a single upload tracked by progress.
+type transfer struct {
+	name  string
+	sent  int64
+	total int64
+	start time.Time
+}
+
+// uploads tracks the progress of every upload made by attachFile.
+var uploads = &progress{}
+
+// begin starts tracking an upload of total bytes, or of an unknown size when
+// total is negative.
+func (p *progress) begin(name string, total int64) *transfer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := &transfer{name: name, total: total, start: time.Now()}
+	p.transfers = append(p.transfers, t)
+	return t
+}
+
+// end records that the upload t has finished.
+func (p *progress) end(t *transfer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, u := range p.transfers {
+		if u == t {
+			p.transfers = append(p.transfers[:i], p.transfers[i+1:]...)
+			break
+		}
+	}
+}
+
+func (p *progress) add(t *transfer, n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.sent += n
+}
+
+// current returns the uploads in flight combined into one, named after the
+// upload when there is only one, or ok false when nothing is being uploaded.
+// The combined size is unknown if any upload's is.
+func (p *progress) current() (t transfer, ok bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch len(p.transfers) {
+	case 0:
+		return transfer{}, false
+	case 1:
+		return *p.transfers[0], true
+	}
+	t = transfer{name: fmt.Sprintf("%d files", len(p.transfers)), start: p.transfers[0].start}
+	for _, u := range p.transfers {
+		t.sent += u.sent
+		if u.total < 0 || t.total < 0 {
+			t.total = -1
+		} else {
+			t.total += u.total
+		}
+		if u.start.Before(t.start) {
+			t.start = u.start
+		}
+	}
+	return t, true
+}
+
+// String describes the current uploads, their rate and, when their size is
+// known, the estimated time remaining.
+func (p *progress) String() string {
+	t, ok := p.current()
+	if !ok {
+		return "idle"
+	}
+	elapsed := time.Since(t.start)
+	if t.total < 0 {
+		return fmt.Sprintf("uploading %v: %v sent, %v/s", t.name, formatSize(t.sent), throughput(t.sent, elapsed))
+	}
+	status := fmt.Sprintf("uploading %v: %v of %v", t.name, formatSize(t.sent), formatSize(t.total))
+	if t.total > 0 {
+		status += fmt.Sprintf(" (%d%%)", t.sent*100/t.total)
+	}
+	status += fmt.Sprintf(", %v/s", throughput(t.sent, elapsed))
+	if t.sent > 0 && t.sent < t.total {
+		eta := time.Duration(float64(elapsed) * float64(t.total-t.sent) / float64(t.sent))
+		status += fmt.Sprintf(", about %v left", eta.Round(time.Second))
+	}
+	return status
+}
+
+// progressReader counts the bytes read through it towards the upload t.
+type progressReader struct {
+	r io.Reader
+	p *progress
+	t *transfer
+	n int64
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.p.add(r.t, int64(n))
+	r.n += int64(n)
+	return n, err
+}
+
+// Rewind takes the bytes read so far back off the progress, so a retried
+// upload isn't counted twice.
+func (r *progressReader) Rewind() error {
+	if err := jira.Rewind(r.r, r.n); err != nil {
+		return err
+	}
+	r.p.add(r.t, -r.n)
+	r.n = 0
+	return nil
+}
+
+// remaining returns the number of bytes left to read from r, or -1 when that
+// can't be determined without consuming it.
+func remaining(r io.Reader) int64 {
+	rs, ok := r.(io.Seeker)
+	if !ok {
+		return -1
+	}
+	offset, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
+	}
+	if _, err := rs.Seek(offset, io.SeekStart); err != nil {
+		return -1
+	}
+	return end - offset
+}
+
+// reportProgress prints the progress of the current upload to stderr each
+// time the process is sent SIGUSR1, where supported.
+func reportProgress() {
+	ch := make(chan os.Signal, 1)
+	if !notifyProgress(ch) {
+		return
+	}
+	go func() {
+		for range ch {
+			fmt.Fprintln(os.Stderr, uploads)
+		}
+	}()
+}
+
+// writeProgress rewrites the file at path with the progress of the current
+// upload every second until the returned function is called.
+func writeProgress(path string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	write := func() {
+		if err := ioutil.WriteFile(path, []byte(uploads.String()+"\n"), 0644); err != nil {
+			warnf("unable to write status file: %v", err)
+		}
+	}
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			write()
+			select {
+			case <-ticker.C:
+			case <-done:
+				write()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// progressLine is the progress bar drawn on stderr. Drawing it is
+// serialized with withoutProgress so output from parallel uploads is never
+// mixed into it.
+var progressLine struct {
+	sync.Mutex
+	drawn int
+}
+
+// drawProgress replaces the progress bar with line. The caller holds
+// progressLine.
+func drawProgress(line string) {
+	// Lines that wrap can't be redrawn in place, so assume the terminal is
+	// at least 80 columns wide.
+	r := []rune(line)
+	if len(r) > 79 {
+		r = r[:79]
+	}
+	// Pad with spaces rather than using escape sequences, which older
+	// Windows consoles don't understand.
+	pad := progressLine.drawn - len(r)
+	if pad < 0 {
+		pad = 0
+	}
+	fmt.Fprintf(os.Stderr, "\r%v%v\r", string(r), strings.Repeat(" ", pad))
+	progressLine.drawn = len(r)
+}
+
+// withoutProgress runs f, which writes to the terminal, with the progress
+// bar cleared and kept from being redrawn until it returns.
+func withoutProgress(f func()) {
+	progressLine.Lock()
+	defer progressLine.Unlock()
+	if progressLine.drawn > 0 {
+		drawProgress("")
+	}
+	f()
+}
+
+// showProgress draws a progress bar for the current uploads on stderr,
+// redrawing it a few times a second until the returned function is first
+// called. Nothing is drawn when stderr isn't a terminal, so logs stay readable.
+func showProgress() func() {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(200 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progressLine.Lock()
+				if line := uploads.bar(); line != "" || progressLine.drawn > 0 {
+					drawProgress(line)
+				}
+				progressLine.Unlock()
+			case <-done:
+				withoutProgress(func() {})
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+		})
+	}
+}
+
+// bar describes the current uploads as a progress bar followed by their
+// status, or returns "" when nothing is being uploaded.
+func (p *progress) bar() string {
+	status := p.String()
+	if status == "idle" {
+		return ""
+	}
+	t, _ := p.current()
+	if t.total <= 0 {
+		return status
+	}
+	const width = 20
+	filled := int(t.sent * width / t.total)
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "] " + strings.TrimPrefix(status, "uploading ")
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package jiraattach
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// notifyProgress relays SIGUSR1 to ch.
+func notifyProgress(ch chan<- os.Signal) bool {
+	signal.Notify(ch, syscall.SIGUSR1)
+	return true
+}
@@ -0,0 +1,9 @@
+package jiraattach
+
+import "os"
+
+// notifyProgress reports that progress can't be requested with a signal,
+// since Windows has no SIGUSR1. -status-file can be used instead.
+func notifyProgress(ch chan<- os.Signal) bool {
+	return false
+}
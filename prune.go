@@ -0,0 +1,66 @@
+package jiraattach
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+const pruneUsage = `usage: jiraattach prune [-keep-latest=n] [-dry-run] key [pattern]
+
+  Delete all but the newest n versions of each attachment filename on a Jira
+  Issue, optionally only for filenames matching a glob pattern.
+`
+
+// runPrune implements the prune command, keeping only the newest versions of
+// each attachment filename on an issue.
+func runPrune(config *Config, args []string) error {
+	fs := newFlagSet("prune", pruneUsage)
+	keep := fs.Int("keep-latest", 1, "number of versions of each filename to keep")
+	dryrun := fs.Bool("dry-run", false, "report attachments without deleting them")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	pattern := fs.Arg(1)
+	config = config.route(key)
+	if *keep < 1 {
+		return fmt.Errorf("keep-latest must be at least 1")
+	}
+
+	c := config.client()
+	attachments, err := c.attachments(key)
+	if err != nil {
+		return fmt.Errorf("error listing attachments on %v: %w", key, err)
+	}
+	sortAttachments(attachments)
+
+	// Walk from newest to oldest so the first versions seen are the ones kept.
+	seen := map[string]int{}
+	for i := len(attachments) - 1; i >= 0; i-- {
+		a := attachments[i]
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, a.Filename); !ok {
+				continue
+			}
+		}
+		seen[a.Filename]++
+		if seen[a.Filename] <= *keep {
+			continue
+		}
+		if *dryrun {
+			fmt.Printf("would delete %v %v from %v\n", a.ID, a.Filename, a.Created.Format("2006-01-02 15:04"))
+			continue
+		}
+		if err := c.deleteAttachment(key, a); err != nil {
+			return fmt.Errorf("error deleting attachment %v: %w", a.ID, err)
+		}
+		fmt.Printf("deleted %v %v from %v\n", a.ID, a.Filename, a.Created.Format("2006-01-02 15:04"))
+	}
+	return nil
+}
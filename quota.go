@@ -0,0 +1,102 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+)
+
+// issueUsage is the space taken by the attachments on an issue.
+type issueUsage struct {
+	Count int
+	Total int64
+}
+
+// usage sums the sizes of the attachments on the issue identified by key.
+func (c *client) usage(key string) (issueUsage, error) {
+	attachments, err := c.attachments(key)
+	if err != nil {
+		return issueUsage{}, fmt.Errorf("error listing attachments on %v: %w", key, err)
+	}
+	u := issueUsage{Count: len(attachments)}
+	for _, a := range attachments {
+		u.Total += a.Size
+	}
+	return u, nil
+}
+
+// issueBudget returns the configured per-issue budget in bytes, or 0 when
+// there is none.
+func (c *Config) issueBudget() int64 {
+	if c.IssueBudget == "" {
+		return 0
+	}
+	n, _ := parseSize(c.IssueBudget)
+	return n
+}
+
+// checkBudget warns when adding bytes to the issue would take it over the
+// per-issue budget, or refuses when enforce is set.
+func checkBudget(config *Config, key string, adding int64, enforce bool) error {
+	budget := config.issueBudget()
+	if budget == 0 {
+		if enforce {
+			return fmt.Errorf("-enforce-budget needs issue_budget to be set in the config file")
+		}
+		return nil
+	}
+	u, err := config.client().usage(key)
+	if err != nil {
+		return err
+	}
+	if u.Total+adding <= budget {
+		return nil
+	}
+	msg := fmt.Sprintf("%v would use %v of its %v budget", key, formatSize(u.Total+adding), formatSize(budget))
+	if enforce {
+		return fmt.Errorf("refusing to attach, %v", msg)
+	}
+	warnf("%v", msg)
+	return nil
+}
+
+const quotaUsage = `usage: jiraattach quota key
+
+  Show the number and total size of the attachments on a Jira Issue, how
+  much of issue_budget they use and the instance's limit on the size of each
+  file.
+`
+
+// runQuota implements the quota command, reporting how much of its budget
+// an issue's attachments use.
+func runQuota(config *Config, args []string) error {
+	fs := newFlagSet("quota", quotaUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+
+	c := config.client()
+	u, err := c.usage(key)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("attachments  %d\n", u.Count)
+	fmt.Printf("total        %v\n", formatSize(u.Total))
+	if budget := config.issueBudget(); budget > 0 {
+		fmt.Printf("budget       %v (%d%% used)\n", formatSize(budget), u.Total*100/budget)
+		if u.Total > budget {
+			fmt.Fprintf(os.Stderr, "%v is %v over its budget\n", key, formatSize(u.Total-budget))
+		}
+	}
+	if caps, err := c.capabilities(); err == nil && caps.UploadLimit > 0 {
+		fmt.Printf("file limit   %v\n", formatSize(caps.UploadLimit))
+	}
+	return nil
+}
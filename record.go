@@ -0,0 +1,254 @@
+package jiraattach
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const recordUsage = `usage: jiraattach record [-format=log|cast] [-timestamps] [-name=filename]
+  key -- command [args...]
+
+  Run the command, showing and capturing its stdout and stderr together,
+  then attach the capture and post a comment with the command's exit status.
+  -format cast records it for asciinema play, and -timestamps starts each
+  line of a log with the time it was written. Ctrl-C stops the command but
+  the capture is still attached, and jiraattach exits with the command's
+  status.
+`
+
+// runRecord implements the record command, running a command while
+// capturing its output and attaching the capture.
+func runRecord(config *Config, args []string) error {
+	fs := newFlagSet("record", recordUsage)
+	format := fs.String("format", "log", "capture format, log for the output as it was written or cast for an asciinema recording")
+	timestamps := fs.Bool("timestamps", false, "with -format log, start each line with the time it was written")
+	name := fs.String("name", "", "filename to attach the capture as, record-<time>.log or .cast by default")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) > 1 && args[1] == "--" {
+		args = append(args[:1], args[2:]...)
+	}
+	if len(args) < 2 {
+		return usageErrorf("key and command are required")
+	}
+	if *format != "log" && *format != "cast" {
+		return usageErrorf("invalid -format %q, expected log or cast", *format)
+	}
+	if *timestamps && *format != "log" {
+		return usageErrorf("-timestamps can only be used with -format log")
+	}
+	key, err := config.issueKey(args[0])
+	if err != nil {
+		return err
+	}
+	command := args[1:]
+	config = config.route(key)
+	if *name == "" {
+		*name = fmt.Sprintf("record-%v.%v", time.Now().UTC().Format("20060102T150405Z"), *format)
+	} else if err := checkName(*name); err != nil {
+		return usageErrorf("invalid -name: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile("", "jiraattach-record-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	rec := &recorder{w: tmp, format: *format, timestamps: *timestamps, start: time.Now()}
+	if *format == "cast" {
+		rec.header(command)
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = io.MultiWriter(os.Stdout, rec)
+	cmd.Stderr = io.MultiWriter(os.Stderr, rec)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error running %v: %w", command[0], err)
+	}
+	// Ctrl-C reaches the command from the terminal, and SIGTERM is passed
+	// on to it, so either stops the command but not the recording.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		for sig := range signals {
+			if sig != os.Interrupt {
+				cmd.Process.Signal(sig)
+			}
+		}
+	}()
+	// Wait's error only reports the exit status, read from ProcessState.
+	cmd.Wait()
+	signal.Stop(signals)
+	close(signals)
+	elapsed := time.Since(rec.start)
+	status := cmd.ProcessState.ExitCode()
+	outcome := fmt.Sprintf("exited with status %d", status)
+	if status < 0 {
+		status, outcome = exitInterrupted, "was stopped, "+cmd.ProcessState.String()
+	}
+	if rec.err != nil {
+		return fmt.Errorf("error capturing output: %v", rec.err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("error reading capture: %w", err)
+	}
+
+	err = runCancellable(config, func(config *Config, _ []string) error {
+		attachments, err := attachFile(config, key, *name, tmp)
+		if err != nil {
+			return err
+		}
+		for _, a := range attachments {
+			fmt.Println(a.Content)
+		}
+		if _, err := config.client().comment(key, recordComment(command, status == 0, outcome, elapsed, *name), commentOptions{}); err != nil {
+			return fmt.Errorf("error commenting on %v: %w", key, err)
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return &exitError{code: status, err: fmt.Errorf("%v %v", command[0], outcome)}
+	}
+	return nil
+}
+
+// attachExec runs command with the shell and attaches its stdout to the
+// issue as name, streaming it as it is written, then comments with its exit
+// status. stderr is left on the terminal. A command that fails makes
+// jiraattach exit with exitCommand, whatever its own status, so that it
+// can't be mistaken for one of jiraattach's.
+func attachExec(config *Config, key, command, name string, opts commentOptions) error {
+	if strings.TrimSpace(command) == "" {
+		return usageErrorf("-exec needs a shell command to run")
+	}
+	if name == "" {
+		name = sanitizeName(filepath.Base(strings.Fields(command)[0])) + ".log"
+	} else if err := checkName(name); err != nil {
+		return usageErrorf("invalid -filename: %v", err)
+	}
+	cmd := exec.Command("sh", "-c", command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("error running %v: %w", command, err)
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error running %v: %w", command, err)
+	}
+	attachments, err := attachFile(config, key, name, stdout)
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+	// Wait's error only reports the exit status, read from ProcessState.
+	cmd.Wait()
+	status := cmd.ProcessState.ExitCode()
+	outcome := fmt.Sprintf("exited with status %d", status)
+	if status < 0 {
+		outcome = "was stopped, " + cmd.ProcessState.String()
+	}
+	for _, a := range attachments {
+		fmt.Println(a.Content)
+	}
+	if _, err := config.client().comment(key, recordComment([]string{command}, status == 0, outcome, time.Since(start), name), opts); err != nil {
+		return fmt.Errorf("error commenting on %v: %w", key, err)
+	}
+	if status != 0 {
+		return &exitError{code: exitCommand, err: fmt.Errorf("%v %v", command, outcome)}
+	}
+	return nil
+}
+
+// recordComment describes, in wiki markup, how the recorded command ended
+// and links to the capture attached as name.
+func recordComment(command []string, ok bool, outcome string, elapsed time.Duration, name string) string {
+	icon := "(/)"
+	if !ok {
+		icon = "(x)"
+	}
+	return fmt.Sprintf("%v {{%v}} %v after %v. Output: [^%v]",
+		icon, strings.Join(command, " "), outcome, elapsed.Round(time.Millisecond), name)
+}
+
+// recorder captures the output of a command written to it from its stdout
+// and stderr at once. Failing to write the capture doesn't fail the
+// command's own writes; the first error is kept in err instead.
+type recorder struct {
+	mu         sync.Mutex
+	w          io.Writer
+	format     string
+	timestamps bool
+	start      time.Time
+	midline    bool
+	err        error
+}
+
+// header writes the header of an asciinema v2 recording of command.
+func (r *recorder) header(command []string) {
+	width, height := 80, 24
+	if out, err := stty("size"); err == nil {
+		fmt.Sscan(out, &height, &width)
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     width,
+		"height":    height,
+		"timestamp": r.start.Unix(),
+		"command":   strings.Join(command, " "),
+	})
+	r.write(append(data, '\n'))
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case r.format == "cast":
+		data, _ := json.Marshal(string(p))
+		r.write([]byte(fmt.Sprintf("[%.6f, \"o\", %s]\n", time.Since(r.start).Seconds(), data)))
+	case r.timestamps:
+		for _, line := range strings.SplitAfter(string(p), "\n") {
+			if line == "" {
+				continue
+			}
+			if !r.midline {
+				r.write([]byte(time.Now().Format("2006-01-02T15:04:05.000Z07:00 ")))
+			}
+			r.write([]byte(line))
+			r.midline = !strings.HasSuffix(line, "\n")
+		}
+	default:
+		r.write(p)
+	}
+	return len(p), nil
+}
+
+func (r *recorder) write(p []byte) {
+	if r.err == nil {
+		_, r.err = r.w.Write(p)
+	}
+}
@@ -0,0 +1,79 @@
+package jiraattach
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// redacted replaces credentials removed from diagnostics.
+const redacted = "[REDACTED]"
+
+// credentialPatterns match credentials that can be recognized without
+// knowing them: authentication and cookie headers, bearer and basic tokens,
+// passwords in URLs and token fields in JSON and query strings.
+var credentialPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)((?:proxy-)?authorization|cookie|set-cookie)(\s*[:=]\s*)[^\r\n]+`), "${1}${2}" + redacted},
+	{regexp.MustCompile(`(?i)\b(bearer|basic)(\s+)[A-Za-z0-9._~+/=-]{8,}`), "${1}${2}" + redacted},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + redacted + "@"},
+	{regexp.MustCompile(`(?i)("(?:access_token|refresh_token|id_token|client_secret|password|token)"\s*:\s*)"[^"]*"`), `${1}"` + redacted + `"`},
+	{regexp.MustCompile(`(?i)([?&](?:access_token|token|sig|signature|x-amz-signature|x-goog-signature)=)[^&\s"]+`), "${1}" + redacted},
+}
+
+// credentials holds the secrets in use, such as the configured password,
+// so that they can be removed from diagnostics wherever they appear.
+var credentials struct {
+	mu     sync.Mutex
+	values []string
+}
+
+// addCredential registers a secret to be redacted. The base64 form used in
+// basic authentication headers is registered by addBasicAuth.
+func addCredential(secret string) {
+	if len(secret) < 4 {
+		return // too short to redact without mangling unrelated text
+	}
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+	for _, v := range credentials.values {
+		if v == secret {
+			return
+		}
+	}
+	credentials.values = append(credentials.values, secret)
+}
+
+// addBasicAuth registers the password and the encoded credentials of a
+// basic authentication header.
+func addBasicAuth(user, pass string) {
+	addCredential(pass)
+	if user != "" || pass != "" {
+		addCredential(base64.StdEncoding.EncodeToString([]byte(user + ":" + pass)))
+	}
+}
+
+// redact removes every known and recognizable credential from s. Anything
+// shown to the user that could contain request or response data, such as
+// error messages, echoed response bodies and traces, goes through redact.
+func redact(s string) string {
+	credentials.mu.Lock()
+	for _, v := range credentials.values {
+		s = strings.Replace(s, v, redacted, -1)
+	}
+	credentials.mu.Unlock()
+	for _, p := range credentialPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// warnf prints a warning to stderr with any credentials in it redacted.
+func warnf(format string, args ...interface{}) {
+	fmt.Fprintln(os.Stderr, redact(fmt.Sprintf("warning: "+format, args...)))
+}
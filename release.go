@@ -0,0 +1,229 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// defaultReleaseTemplate renders the release comment when no template file
+// is given.
+const defaultReleaseTemplate = `h3. Release {{.Version}}
+
+{{range .Files}}* [^{{.Filename}}] ({{.Size}}, sha256 {{.SHA256}})
+{{end}}{{with .Changelog}}
+h4. Changes
+
+{{.}}
+{{end}}`
+
+// releaseData is passed to the release comment template.
+type releaseData struct {
+	Issue      string
+	Version    string
+	FixVersion string
+	Files      []releaseFile
+	Changelog  string
+}
+
+type releaseFile struct {
+	Filename string
+	Path     string
+	Size     string
+	SHA256   string
+}
+
+const releaseUsage = `usage: jiraattach release [-version=v] [-fix-version=name] [-changelog=path]
+  [-template=path] key pattern...
+
+  Attach release artifacts, add the fix version to the Jira Issue and post a
+  comment listing the artifacts and the release notes from the changelog.
+  Patterns are globs that may contain {version}, such as
+  dist/app-{version}-*.tar.gz, to match files of the requested version or of
+  the newest semantic version found.
+`
+
+// runRelease implements the release command, attaching a release's
+// artifacts to an issue, setting its fix version and posting a summary.
+func runRelease(config *Config, args []string) error {
+	fs := newFlagSet("release", releaseUsage)
+	version := fs.String("version", "", "version being released, defaults to the newest version matched")
+	fixversion := fs.String("fix-version", "", "fix version to add to the issue, defaults to the version")
+	changelog := fs.String("changelog", "", "path to a changelog to take the release notes from")
+	tmplpath := fs.String("template", "", "path to a text/template for the release comment")
+	allowsecrets := fs.Bool("allow-secrets", config.AllowSecrets, "attach files even if they appear to contain secrets")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	config.AllowSecrets = *allowsecrets
+	if fs.NArg() < 2 {
+		return usageErrorf("key and at least one pattern are required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	patterns := fs.Args()[1:]
+	config = config.route(key)
+
+	tmpl := defaultReleaseTemplate
+	if *tmplpath != "" {
+		data, err := ioutil.ReadFile(*tmplpath)
+		if err != nil {
+			return fmt.Errorf("error reading template: %w", err)
+		}
+		tmpl = string(data)
+	}
+	t, err := template.New("release").Parse(tmpl)
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	paths, v, err := matchArtifacts(patterns, *version)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no artifacts match %v", strings.Join(patterns, " "))
+	}
+	data := releaseData{Issue: key, Version: v, FixVersion: *fixversion}
+	if data.FixVersion == "" {
+		data.FixVersion = strings.TrimPrefix(v, "v")
+	}
+	if *changelog != "" {
+		text, err := ioutil.ReadFile(*changelog)
+		if err != nil {
+			return fmt.Errorf("error reading changelog: %w", err)
+		}
+		data.Changelog = changelogSection(string(text), v)
+	}
+
+	for _, path := range paths {
+		sum, err := hashFile(path)
+		if err != nil {
+			return fmt.Errorf("error reading attachment, %v: %w", path, err)
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("error reading attachment, %v: %w", path, err)
+		}
+		attachments, err := attachFile(config, key, filepath.Base(path), file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("error uploading %v: %w", path, err)
+		}
+		for _, a := range attachments {
+			data.Files = append(data.Files, releaseFile{Filename: a.Filename, Path: path, Size: formatSize(a.Size), SHA256: sum})
+		}
+	}
+
+	c := config.client()
+	edit := map[string]interface{}{
+		"update": map[string]interface{}{
+			"fixVersions": []interface{}{
+				map[string]interface{}{"add": map[string]string{"name": data.FixVersion}},
+			},
+		},
+	}
+	if err := c.updateIssue(key, edit); err != nil {
+		return fmt.Errorf("error setting fix version %v on %v: %w", data.FixVersion, key, err)
+	}
+
+	body := &bytes.Buffer{}
+	if err := t.Execute(body, data); err != nil {
+		return fmt.Errorf("error rendering release comment: %w", err)
+	}
+	if _, err := c.comment(key, body.String(), commentOptions{}); err != nil {
+		return fmt.Errorf("error commenting on %v: %w", key, err)
+	}
+	return nil
+}
+
+// matchArtifacts expands the glob patterns. Patterns may contain a {version}
+// placeholder matching a semantic version, in which case only files of the
+// requested version, or of the newest version found when none is requested,
+// are returned. It returns the matched paths and the release version.
+func matchArtifacts(patterns []string, version string) ([]string, string, error) {
+	byVersion := map[string][]string{}
+	var plain []string
+	for _, pattern := range patterns {
+		if !strings.Contains(pattern, "{version}") {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid pattern %v: %w", pattern, err)
+			}
+			plain = append(plain, matches...)
+			continue
+		}
+		glob, re, err := versionGlob(filepath.ToSlash(pattern))
+		if err != nil {
+			return nil, "", usageErrorf("invalid pattern %v: %v", pattern, err)
+		}
+		matches, err := filepath.Glob(filepath.FromSlash(glob))
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid pattern %v: %w", pattern, err)
+		}
+		for _, path := range matches {
+			m := re.FindStringSubmatch(filepath.ToSlash(path))
+			if m == nil {
+				continue
+			}
+			byVersion[m[1]] = append(byVersion[m[1]], path)
+		}
+	}
+
+	if version == "" {
+		var newest semver
+		for v := range byVersion {
+			parsed, _ := parseSemver(v)
+			if version == "" || newest.less(parsed) {
+				version, newest = v, parsed
+			}
+		}
+	}
+	if version == "" {
+		return nil, "", usageErrorf("version is required when patterns don't contain {version}")
+	}
+
+	paths := append(plain, byVersion[version]...)
+	// Versions may be written with or without a leading v.
+	if strings.HasPrefix(version, "v") {
+		paths = append(paths, byVersion[strings.TrimPrefix(version, "v")]...)
+	} else {
+		paths = append(paths, byVersion["v"+version]...)
+	}
+	sort.Strings(paths)
+	return paths, version, nil
+}
+
+// changelogSection returns the section of a markdown changelog whose heading
+// mentions version, up to the next heading of the same or a higher level.
+// Longer versions such as 1.2.10 or 1.2.1-rc.1 don't count as mentioning
+// 1.2.1.
+func changelogSection(changelog, version string) string {
+	mentions := regexp.MustCompile(`(^|[^0-9A-Za-z.])v?` + regexp.QuoteMeta(strings.TrimPrefix(version, "v")) + `($|[^0-9A-Za-z.+-]|\.[^0-9])`)
+	var (
+		section []string
+		level   int
+	)
+	for _, line := range strings.Split(changelog, "\n") {
+		depth := len(line) - len(strings.TrimLeft(line, "#"))
+		if depth > 0 && level > 0 && depth <= level {
+			break
+		}
+		if level > 0 {
+			section = append(section, line)
+			continue
+		}
+		if depth > 0 && mentions.MatchString(line) {
+			level = depth
+		}
+	}
+	return strings.TrimSpace(strings.Join(section, "\n"))
+}
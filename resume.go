@@ -0,0 +1,142 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// pendingUploadsFile is the state file recording uploads of local files split
+// into parts that attached some parts but not all, so that a later run can
+// resume them.
+const pendingUploadsFile = "pending.json"
+
+// pendingUpload is an upload of a local file split into parts. It is saved
+// once the first part is attached, until the last one is. Jira takes each
+// attachment in a single request, so only split files can be resumed,
+// skipping the parts already attached; nothing is saved for a whole file,
+// which can only be sent again from the start.
+type pendingUpload struct {
+	Issue    string       `json:"issue"`
+	Path     string       `json:"path"`
+	Size     int64        `json:"size"`
+	Modified time.Time    `json:"modified"`
+	Started  time.Time    `json:"started"`
+	Filename string       `json:"filename,omitempty"`
+	Parts    int          `json:"parts,omitempty"`
+	Attached []Attachment `json:"attached,omitempty"`
+
+	resumed bool
+	// saved is set once the upload has been recorded, and replaced is set
+	// when it replaces an earlier one; either needs clearing when it ends.
+	saved    bool
+	replaced bool
+}
+
+// pendingMu serializes updates to the pending uploads file between parallel
+// uploads.
+var pendingMu sync.Mutex
+
+// startUpload checks for an unfinished earlier upload of the file at path,
+// described by info, to the issue and returns the config to upload it with,
+// which tracks its progress so that it can be resumed in turn. With -resume
+// the earlier upload is continued where possible; otherwise it is restarted,
+// saying what was left of it.
+func (c *Config) startUpload(key, path string, info os.FileInfo) *Config {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	p := &pendingUpload{Issue: key, Path: abs, Size: info.Size(), Modified: info.ModTime(), Started: time.Now()}
+	prev, err := findPendingUpload(key, abs)
+	if err != nil {
+		warnf("ignoring unfinished uploads: %v", err)
+	}
+	p.replaced = prev != nil
+	switch {
+	case prev == nil:
+	case prev.Size != p.Size || !prev.Modified.Equal(p.Modified):
+		warnf("%v has changed since its upload to %v was interrupted, uploading it from the start", path, key)
+	case !c.resume:
+		warnf("an upload of %v to %v started %v stopped with %d of %d parts attached; uploading it again from the start, -resume would continue it",
+			path, key, prev.Started.Format("2006-01-02 15:04"), len(prev.Attached), prev.Parts)
+	default:
+		p = prev
+		p.resumed = true
+	}
+	routed := *c
+	routed.pending = p
+	return &routed
+}
+
+// save records p as unfinished, forgetting unfinished uploads of files that
+// no longer exist.
+func (p *pendingUpload) save() error {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	var pending []*pendingUpload
+	if err := loadState(pendingUploadsFile, &pending); err != nil {
+		return err
+	}
+	var kept []*pendingUpload
+	for _, q := range removePending(pending, p.Issue, p.Path) {
+		if _, err := os.Stat(q.Path); err == nil {
+			kept = append(kept, q)
+		}
+	}
+	pending = append(kept, p)
+	p.saved = true
+	return saveState(pendingUploadsFile, pending)
+}
+
+// finish records that p is done.
+func (p *pendingUpload) finish() error {
+	if !p.saved && !p.replaced {
+		return nil
+	}
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	var pending []*pendingUpload
+	if err := loadState(pendingUploadsFile, &pending); err != nil {
+		return err
+	}
+	return saveState(pendingUploadsFile, removePending(pending, p.Issue, p.Path))
+}
+
+// interrupted reports whether p stopped with some of its parts attached, so
+// that it can be resumed, and describes how far it got.
+func (p *pendingUpload) interrupted(name string) (string, bool) {
+	if len(p.Attached) == 0 {
+		return "", false
+	}
+	return fmt.Sprintf("the upload of %v stopped with %d of %d parts attached; run again with -resume to attach the rest", name, len(p.Attached), p.Parts), true
+}
+
+// findPendingUpload returns the unfinished upload of the file at path to the
+// issue, or nil when there is none.
+func findPendingUpload(key, path string) (*pendingUpload, error) {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	var pending []*pendingUpload
+	if err := loadState(pendingUploadsFile, &pending); err != nil {
+		return nil, err
+	}
+	for _, p := range pending {
+		if p.Issue == key && p.Path == path {
+			return p, nil
+		}
+	}
+	return nil, nil
+}
+
+func removePending(pending []*pendingUpload, key, path string) []*pendingUpload {
+	var kept []*pendingUpload
+	for _, p := range pending {
+		if p.Issue != key || p.Path != path {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,111 @@
+package jiraattach
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const retentionUsage = `usage: jiraattach retention -project=key -older-than=age [-jql=query]
+  [-rate=n] [-dry-run] [-yes]
+
+  Delete attachments older than age, such as 365d, from every issue in a
+  project, at most rate deletions per second, after reporting them and
+  asking for confirmation.
+`
+
+// runRetention implements the retention command, deleting attachments older
+// than a given age across a project.
+func runRetention(config *Config, args []string) error {
+	fs := newFlagSet("retention", retentionUsage)
+	project := fs.String("project", "", "key of the project to enforce the policy on")
+	olderthan := fs.String("older-than", "", "delete attachments older than this, such as 365d")
+	jql := fs.String("jql", "", "additional JQL restricting which issues are checked")
+	rate := fs.Float64("rate", 5, "maximum deletions per second")
+	dryrun := fs.Bool("dry-run", false, "report attachments without deleting them")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *project == "" || *olderthan == "" {
+		return usageErrorf("project and older-than are required")
+	}
+	age, err := parseAge(*olderthan)
+	if err != nil {
+		return err
+	}
+	if *rate <= 0 {
+		return fmt.Errorf("rate must be greater than 0")
+	}
+
+	config = config.route(*project + "-")
+	query := fmt.Sprintf("project = %q AND attachments IS NOT EMPTY", *project)
+	if *jql != "" {
+		query += " AND (" + *jql + ")"
+	}
+	query += " ORDER BY key"
+
+	c := config.client()
+	issues, err := c.search(query, "attachment")
+	if err != nil {
+		return fmt.Errorf("error searching for issues: %w", err)
+	}
+
+	type expired struct {
+		key string
+		Attachment
+	}
+	cutoff := time.Now().Add(-age)
+	var (
+		violations []expired
+		total      int64
+		undated    int
+	)
+	for _, issue := range issues {
+		for _, a := range issue.Fields.Attachments {
+			// An attachment without a creation time would look older than
+			// any cutoff, so it is never deleted.
+			if a.Created.IsZero() {
+				undated++
+				continue
+			}
+			if a.Created.Before(cutoff) {
+				violations = append(violations, expired{issue.Key, a})
+				total += a.Size
+			}
+		}
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, v := range violations {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\n", v.key, v.ID, v.Created.Format("2006-01-02"), formatSize(v.Size), v.Filename)
+	}
+	tw.Flush()
+	fmt.Fprintf(os.Stderr, "%d attachments (%v) on %d issues are older than %v\n", len(violations), formatSize(total), len(issues), *olderthan)
+	if undated > 0 {
+		warnf("skipping %d attachments that Jira didn't give a creation time", undated)
+	}
+
+	if *dryrun || len(violations) == 0 {
+		return nil
+	}
+	if !*yes && !confirm(fmt.Sprintf("Delete %d attachments from %v?", len(violations), *project), false) {
+		return nil
+	}
+	throttle := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+	defer throttle.Stop()
+	ctx := c.context()
+	for i, v := range violations {
+		select {
+		case <-throttle.C:
+		case <-ctx.Done():
+			fmt.Fprintf(os.Stderr, "stopped after deleting %d of %d attachments\n", i, len(violations))
+			return ctx.Err()
+		}
+		if err := c.deleteAttachment(v.key, v.Attachment); err != nil {
+			return fmt.Errorf("error deleting attachment %v from %v: %w", v.ID, v.key, err)
+		}
+	}
+	return nil
+}
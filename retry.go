@@ -0,0 +1,271 @@
+package jiraattach
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// duration is a time.Duration read from JSON as a string such as "500ms".
+type duration struct {
+	time.Duration
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("durations must be strings such as \"1s\"")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// RetryPolicy controls how failed requests are retried. Zero fields take
+// their value from the default policy, or for operation overrides from the
+// top level policy.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a request is tried, including the
+	// first. 1 disables retries.
+	MaxAttempts int `json:"max_attempts"`
+
+	// BaseDelay is the wait before the first retry, doubling for each
+	// retry after it up to MaxDelay.
+	BaseDelay duration `json:"base_delay"`
+	MaxDelay  duration `json:"max_delay"`
+
+	// Jitter is the fraction, between 0 and 1, of each wait that is
+	// randomized so that many clients don't retry in lockstep.
+	Jitter *float64 `json:"jitter"`
+
+	// RetryStatus lists the response status codes that are retried. Left
+	// out, 429 and every 5xx status are. Network errors are always retried.
+	RetryStatus []int `json:"retry_status"`
+}
+
+// RetryConfig is the retry policy, with optional per-operation overrides
+// for attach, comment, update, delete and read requests.
+type RetryConfig struct {
+	RetryPolicy
+	Operations map[string]RetryPolicy `json:"operations"`
+}
+
+// defaultRetryPolicy doesn't retry, preserving the behavior of earlier
+// versions unless retries are configured.
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 1,
+	BaseDelay:   duration{500 * time.Millisecond},
+	MaxDelay:    duration{30 * time.Second},
+	Jitter:      func() *float64 { j := 0.5; return &j }(),
+}
+
+// merge returns p with its zero fields taken from def.
+func (p RetryPolicy) merge(def RetryPolicy) RetryPolicy {
+	if p.MaxAttempts == 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	if p.BaseDelay.Duration == 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay.Duration == 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Jitter == nil {
+		p.Jitter = def.Jitter
+	}
+	if p.RetryStatus == nil {
+		p.RetryStatus = def.RetryStatus
+	}
+	return p
+}
+
+// policy returns the retry policy for the named operation.
+func (c RetryConfig) policy(op string) RetryPolicy {
+	p := c.RetryPolicy.merge(defaultRetryPolicy)
+	if override, ok := c.Operations[op]; ok {
+		p = override.merge(p)
+	}
+	return p
+}
+
+func (c RetryConfig) validate() error {
+	policies := []RetryPolicy{c.RetryPolicy}
+	for op, p := range c.Operations {
+		switch op {
+		case "attach", "comment", "update", "delete", "read":
+		default:
+			return fmt.Errorf("invalid retry operation %q, expected attach, comment, update, delete or read", op)
+		}
+		policies = append(policies, p)
+	}
+	for _, p := range policies {
+		if p.MaxAttempts < 0 {
+			return fmt.Errorf("invalid retry max_attempts %v", p.MaxAttempts)
+		}
+		if p.Jitter != nil && (*p.Jitter < 0 || *p.Jitter > 1) {
+			return fmt.Errorf("invalid retry jitter %v, must be between 0 and 1", *p.Jitter)
+		}
+	}
+	return nil
+}
+
+// override applies the -retries and -retry-max-wait flags on top of the
+// configured policy and every operation override, since flags given for a
+// run take precedence over the config file. Negative or zero values leave
+// the setting alone.
+func (c *RetryConfig) override(retries int, maxWait time.Duration) {
+	set := func(p *RetryPolicy) {
+		if retries >= 0 {
+			p.MaxAttempts = retries + 1
+		}
+		if maxWait > 0 {
+			p.MaxDelay.Duration = maxWait
+			if p.BaseDelay.Duration > maxWait {
+				p.BaseDelay.Duration = maxWait
+			}
+		}
+	}
+	set(&c.RetryPolicy)
+	for op, p := range c.Operations {
+		set(&p)
+		c.Operations[op] = p
+	}
+}
+
+// retryable reports whether a response with the given status code should be
+// retried.
+func (p RetryPolicy) retryable(code int) bool {
+	if p.RetryStatus == nil {
+		return code == http.StatusTooManyRequests || code >= 500 && code < 600
+	}
+	for _, c := range p.RetryStatus {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the given retry, counting from 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay.Duration
+	for i := 1; i < retry && d < p.MaxDelay.Duration; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay.Duration {
+		d = p.MaxDelay.Duration
+	}
+	if p.Jitter != nil && *p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 - *p.Jitter*rand.Float64()))
+	}
+	return d
+}
+
+// operation classifies a request for choosing its retry policy.
+func operation(req *http.Request) string {
+	switch req.Method {
+	case "GET", "HEAD":
+		return "read"
+	case "PUT":
+		return "update"
+	case "DELETE":
+		return "delete"
+	}
+	if strings.HasSuffix(req.URL.Path, "/attachments") {
+		return "attach"
+	}
+	if strings.HasSuffix(req.URL.Path, "/comment") {
+		return "comment"
+	}
+	return "update"
+}
+
+// Rate limited responses that say when to come back with Retry-After are
+// retried up to rateLimitAttempts times even when retries aren't configured,
+// as long as the wait is no longer than maxRetryAfter.
+const (
+	rateLimitAttempts = 5
+	maxRetryAfter     = 5 * time.Minute
+)
+
+// retryAfter returns the wait requested by the response's Retry-After
+// header, given either in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// doWithRetry sends req, retrying network errors and retryable responses
+// according to the policy for the request's operation. When the response
+// has a Retry-After header its wait is used instead of the backoff, and
+// rate limited requests are retried even when the policy doesn't retry.
+// Requests whose body can't be replayed are only tried once.
+func (c *client) doWithRetry(req *http.Request) (*http.Response, error) {
+	policy := c.retry.policy(operation(req))
+	for attempt := 1; ; attempt++ {
+		resp, err := c.http.Do(req)
+		if req.Context().Err() != nil {
+			return resp, err
+		}
+		var wait time.Duration
+		hasWait, limited := false, false
+		if err == nil {
+			wait, hasWait = retryAfter(resp)
+			limited = hasWait && resp.StatusCode == http.StatusTooManyRequests
+		}
+		if err == nil && !policy.retryable(resp.StatusCode) && !limited {
+			return resp, nil
+		}
+		attempts := policy.MaxAttempts
+		if limited && attempts < rateLimitAttempts {
+			attempts = rateLimitAttempts
+		}
+		if attempt >= attempts || wait > maxRetryAfter || (req.Body != nil && req.GetBody == nil) {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if !hasWait {
+			wait = policy.delay(attempt)
+		}
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+		atomic.AddInt64(&c.retries, 1)
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package jiraattach
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryable(t *testing.T) {
+	def := RetryConfig{}.policy("read")
+	listed := RetryPolicy{RetryStatus: []int{503}}.merge(defaultRetryPolicy)
+	none := RetryPolicy{RetryStatus: []int{}}.merge(defaultRetryPolicy)
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		code   int
+		want   bool
+	}{
+		{name: "default ok", policy: def, code: 200, want: false},
+		{name: "default not found", policy: def, code: 404, want: false},
+		{name: "default conflict", policy: def, code: 409, want: false},
+		{name: "default rate limited", policy: def, code: 429, want: true},
+		{name: "default internal server error", policy: def, code: 500, want: true},
+		{name: "default not implemented", policy: def, code: 501, want: true},
+		{name: "default bad gateway", policy: def, code: 502, want: true},
+		{name: "default unavailable", policy: def, code: 503, want: true},
+		{name: "default gateway timeout", policy: def, code: 504, want: true},
+		{name: "default cloudflare timeout", policy: def, code: 524, want: true},
+		{name: "listed status", policy: listed, code: 503, want: true},
+		{name: "unlisted status", policy: listed, code: 500, want: false},
+		{name: "unlisted rate limit", policy: listed, code: 429, want: false},
+		{name: "empty list", policy: none, code: 500, want: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.policy.retryable(test.code); got != test.want {
+				t.Errorf("retryable(%d) = %v, want %v", test.code, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	noJitter := 0.0
+	policy := RetryPolicy{
+		BaseDelay: duration{time.Second},
+		MaxDelay:  duration{10 * time.Second},
+		Jitter:    &noJitter,
+	}
+	tests := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{retry: 1, want: time.Second},
+		{retry: 2, want: 2 * time.Second},
+		{retry: 3, want: 4 * time.Second},
+		{retry: 4, want: 8 * time.Second},
+		{retry: 5, want: 10 * time.Second},
+		{retry: 6, want: 10 * time.Second},
+		{retry: 100, want: 10 * time.Second},
+	}
+	for _, test := range tests {
+		if got := policy.delay(test.retry); got != test.want {
+			t.Errorf("delay(%d) = %v, want %v", test.retry, got, test.want)
+		}
+	}
+
+	half := 0.5
+	policy.Jitter = &half
+	for retry := 1; retry < 10; retry++ {
+		got := policy.delay(retry)
+		if got > policy.MaxDelay.Duration {
+			t.Errorf("delay(%d) with jitter = %v, more than max_delay %v", retry, got, policy.MaxDelay.Duration)
+		}
+		if got < 500*time.Millisecond {
+			t.Errorf("delay(%d) with jitter = %v, less than half the base delay", retry, got)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "missing", header: "", wantOK: false},
+		{name: "seconds", header: "120", want: 2 * time.Minute, wantOK: true},
+		{name: "zero", header: "0", want: 0, wantOK: true},
+		{name: "padded", header: " 5 ", want: 5 * time.Second, wantOK: true},
+		{name: "negative", header: "-5", wantOK: false},
+		{name: "fraction", header: "1.5", wantOK: false},
+		{name: "garbage", header: "soon", wantOK: false},
+		{name: "past date", header: "Wed, 21 Oct 2015 07:28:00 GMT", want: 0, wantOK: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if test.header != "" {
+				resp.Header.Set("Retry-After", test.header)
+			}
+			got, ok := retryAfter(resp)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("retryAfter(%q) = %v, %v, want %v, %v", test.header, got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+
+	t.Run("future date", func(t *testing.T) {
+		resp := &http.Response{Header: http.Header{}}
+		resp.Header.Set("Retry-After", time.Now().Add(time.Minute).UTC().Format(http.TimeFormat))
+		got, ok := retryAfter(resp)
+		if !ok || got <= 0 || got > time.Minute {
+			t.Errorf("retryAfter a minute from now = %v, %v, want up to 1m", got, ok)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+package jiraattach
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// attachment fetches the metadata of the attachment with the given ID.
+func (c *client) attachment(id string) (*Attachment, error) {
+	return c.api.Attachment(c.context(), id)
+}
+
+// waitForScan polls each attachment until Data Center attachment scanning
+// has resolved its scan status, failing when an attachment is quarantined
+// or removed. Attachments without a scan status are on instances that don't
+// scan and are available straight away.
+func waitForScan(c *client, attachments []Attachment, timeout time.Duration) error {
+	caps, err := c.capabilities()
+	if err != nil {
+		return err
+	}
+	if caps.cloud() {
+		warnf("Jira Cloud doesn't report attachment scan status, not waiting for scans")
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for _, a := range attachments {
+		interval := 500 * time.Millisecond
+		for {
+			current, err := c.attachment(a.ID)
+			if serr, ok := err.(*statusError); ok && serr.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%v was removed after upload, it may have been quarantined by attachment scanning", a.Filename)
+			}
+			if err != nil {
+				return fmt.Errorf("error checking scan status of %v: %w", a.Filename, err)
+			}
+			switch strings.ToUpper(current.ScanStatus) {
+			case "", "CLEAN", "SCANNED", "NOT_SCANNED", "SKIPPED":
+			case "INFECTED", "QUARANTINED", "BLOCKED", "MALICIOUS":
+				return fmt.Errorf("%v was quarantined by attachment scanning, its scan status is %v", a.Filename, current.ScanStatus)
+			default:
+				if time.Now().Add(interval).After(deadline) {
+					return fmt.Errorf("timed out waiting for %v to be scanned, its scan status is %v", a.Filename, current.ScanStatus)
+				}
+				time.Sleep(interval)
+				if interval < 4*time.Second {
+					interval *= 2
+				}
+				continue
+			}
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const screenshotUsage = `usage: jiraattach screenshot [-name=filename] [-no-embed] key
+
+  Select a region of the screen, attach it as a PNG named after the current
+  time and post a comment showing it unless -no-embed is given. Uses
+  screencapture on macOS, grim and slurp on Wayland, and gnome-screenshot,
+  spectacle, maim, scrot or ImageMagick's import on X11. On Windows the
+  whole screen is captured.
+`
+
+// runScreenshot implements the screenshot command, capturing a region of
+// the screen and attaching it.
+func runScreenshot(config *Config, args []string) error {
+	fs := newFlagSet("screenshot", screenshotUsage)
+	name := fs.String("name", "", "filename to attach the screenshot as, screenshot-<time>.png by default")
+	noembed := fs.Bool("no-embed", false, "don't post a comment showing the screenshot")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usageErrorf("key is required")
+	}
+	key, err := config.issueKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	config = config.route(key)
+	if *name == "" {
+		*name = fmt.Sprintf("screenshot-%v.png", time.Now().UTC().Format("20060102T150405Z"))
+	} else if err := checkName(*name); err != nil {
+		return usageErrorf("invalid -name: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "jiraattach-")
+	if err != nil {
+		return fmt.Errorf("error creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "screenshot.png")
+	if err := captureScreen(path); err != nil {
+		return err
+	}
+	return attachImage(config, key, path, *name, !*noembed)
+}
+
+// screenshotTools are the Linux tools tried in turn to capture a region of
+// the screen to a file, which is given as the last argument.
+var screenshotTools = [][]string{
+	{"gnome-screenshot", "-a", "-f"},
+	{"spectacle", "-r", "-b", "-n", "-o"},
+	{"maim", "-s"},
+	{"scrot", "-s", "-o"},
+	{"import"},
+}
+
+// captureScreen lets the user select a region of the screen and saves it to
+// path as a PNG, with screencapture on macOS, grim and slurp on Wayland or
+// the first of screenshotTools found elsewhere. Windows has no interactive
+// capture tool that can be run this way, so the whole screen is captured.
+func captureScreen(path string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("screencapture", "-i", "-x", path)
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", fmt.Sprintf(
+			"Add-Type -AssemblyName System.Windows.Forms, System.Drawing; "+
+				"$b = [System.Windows.Forms.SystemInformation]::VirtualScreen; "+
+				"$img = New-Object System.Drawing.Bitmap $b.Width, $b.Height; "+
+				"[System.Drawing.Graphics]::FromImage($img).CopyFromScreen($b.Left, $b.Top, 0, 0, $img.Size); "+
+				"$img.Save('%v', [System.Drawing.Imaging.ImageFormat]::Png)",
+			strings.Replace(path, "'", "''", -1)))
+	default:
+		_, grim := exec.LookPath("grim")
+		_, slurp := exec.LookPath("slurp")
+		if os.Getenv("WAYLAND_DISPLAY") != "" && grim == nil && slurp == nil {
+			region, err := exec.Command("slurp").Output()
+			if err != nil {
+				return fmt.Errorf("screenshot cancelled")
+			}
+			cmd = exec.Command("grim", "-g", strings.TrimSpace(string(region)), path)
+			break
+		}
+		for _, tool := range screenshotTools {
+			if _, err := exec.LookPath(tool[0]); err == nil {
+				cmd = exec.Command(tool[0], append(tool[1:], path)...)
+				break
+			}
+		}
+		if cmd == nil {
+			return fmt.Errorf("taking a screenshot needs grim and slurp on Wayland, or gnome-screenshot, spectacle, maim, scrot or ImageMagick's import")
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("error taking screenshot with %v: %v", filepath.Base(cmd.Path), msg)
+		}
+		return fmt.Errorf("error taking screenshot with %v: %w", filepath.Base(cmd.Path), err)
+	}
+	// Most tools exit successfully without writing anything when the
+	// selection is cancelled.
+	if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+		return fmt.Errorf("screenshot cancelled")
+	}
+	return nil
+}
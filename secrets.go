@@ -0,0 +1,88 @@
+package jiraattach
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// secretPatterns are the secrets searched for in text attachments by
+// default. More can be added with the secret_patterns config setting.
+var secretPatterns = map[string]string{
+	"AWS access key":      `\b(AKIA|ASIA)[0-9A-Z]{16}\b`,
+	"AWS secret key":      `(?i)aws.{0,20}secret.{0,20}['"\s:=]+[0-9a-zA-Z/+]{40}\b`,
+	"private key":         `-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`,
+	"JSON web token":      `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`,
+	"GitHub token":        `\bgh[pousr]_[A-Za-z0-9]{36,}\b`,
+	"Slack token":         `\bxox[abprs]-[A-Za-z0-9-]{10,}`,
+	"Atlassian API token": `\bATATT[A-Za-z0-9_=-]{20,}`,
+}
+
+// secretMatch describes where a secret was found, without the secret itself.
+type secretMatch struct {
+	line int
+	kind string
+}
+
+// findSecrets searches text read from r for secrets matching the default
+// patterns and any extra patterns. Binary content is not searched.
+func findSecrets(r io.Reader, extra map[string]string) ([]secretMatch, error) {
+	patterns := map[string]*regexp.Regexp{}
+	for _, set := range []map[string]string{secretPatterns, extra} {
+		for kind, expr := range set {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid secret pattern %q: %w", kind, err)
+			}
+			patterns[kind] = re
+		}
+	}
+	kinds := make([]string, 0, len(patterns))
+	for kind := range patterns {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	br := bufio.NewReader(r)
+	// Like git, treat content with a NUL byte near the start as binary.
+	if head, _ := br.Peek(8000); bytes.IndexByte(head, 0) >= 0 {
+		return nil, nil
+	}
+
+	var matches []secretMatch
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		for _, kind := range kinds {
+			if patterns[kind].MatchString(line) {
+				matches = append(matches, secretMatch{line: n, kind: kind})
+			}
+		}
+		if err == io.EOF {
+			return matches, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checkSecrets returns an error describing the secrets found in r, if any.
+func checkSecrets(filename string, r io.Reader, extra map[string]string) error {
+	matches, err := findSecrets(r, extra)
+	if err != nil {
+		return fmt.Errorf("error scanning %v for secrets: %w", filename, err)
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	lines := make([]string, len(matches))
+	for i, m := range matches {
+		lines[i] = fmt.Sprintf("  line %d: %v", m.line, m.kind)
+	}
+	return fmt.Errorf("refusing to attach %v, it appears to contain secrets:\n%v\nuse -allow-secrets to attach it anyway",
+		filename, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,173 @@
+package jiraattach
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semverPattern matches a semantic version, optionally prefixed with v.
+const semverPattern = `v?[0-9]+\.[0-9]+\.[0-9]+(?:-[0-9A-Za-z.-]+)?(?:\+[0-9A-Za-z.-]+)?`
+
+var semverRegexp = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.([0-9]+)(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+// semver is a parsed semantic version.
+type semver struct {
+	major, minor, patch int
+	pre                 string
+}
+
+func parseSemver(s string) (semver, bool) {
+	m := semverRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+	v.pre = m[4]
+	return v, true
+}
+
+// less reports whether v has lower precedence than w, following the rules
+// of semver.org including the ordering of pre-release identifiers.
+func (v semver) less(w semver) bool {
+	if v.major != w.major {
+		return v.major < w.major
+	}
+	if v.minor != w.minor {
+		return v.minor < w.minor
+	}
+	if v.patch != w.patch {
+		return v.patch < w.patch
+	}
+	if v.pre == "" || w.pre == "" {
+		return v.pre != "" && w.pre == ""
+	}
+	a, b := strings.Split(v.pre, "."), strings.Split(w.pre, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		an, aerr := strconv.Atoi(a[i])
+		bn, berr := strconv.Atoi(b[i])
+		switch {
+		case aerr == nil && berr == nil:
+			return an < bn
+		case aerr == nil:
+			return true
+		case berr == nil:
+			return false
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
+
+// versionGlob turns a glob pattern containing a {version} placeholder into a
+// plain glob and a regular expression that extracts the version from each
+// path matching the glob. The pattern uses the syntax of filepath.Match,
+// with / separating directories.
+func versionGlob(pattern string) (string, *regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	parts := strings.Split(pattern, "{version}")
+	for i, part := range parts {
+		if i > 0 {
+			expr.WriteString("(" + semverPattern + ")")
+		}
+		runes := []rune(part)
+		for j := 0; j < len(runes); j++ {
+			switch r := runes[j]; r {
+			case '*':
+				expr.WriteString(`[^/]*`)
+			case '?':
+				expr.WriteString(`[^/]`)
+			case '[':
+				class, n, err := globClass(runes[j+1:])
+				if err != nil {
+					return "", nil, err
+				}
+				expr.WriteString(class)
+				j += n
+			case '\\':
+				if j+1 == len(runes) {
+					return "", nil, fmt.Errorf("pattern ends with a backslash")
+				}
+				j++
+				expr.WriteString(regexp.QuoteMeta(string(runes[j])))
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		}
+	}
+	expr.WriteString("$")
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.Join(parts, "*"), re, nil
+}
+
+// globClass translates the character class at the start of runes, which
+// follow its opening [, into a regular expression, returning it with the
+// number of runes it took up to and including the closing ]. As in
+// filepath.Match, [^...] is negated, ranges such as a-z are allowed and a
+// backslash escapes the character after it. A class never matches /.
+func globClass(runes []rune) (string, int, error) {
+	var class strings.Builder
+	class.WriteString("[")
+	i := 0
+	if i < len(runes) && runes[i] == '^' {
+		class.WriteString("^/")
+		i++
+	}
+	literal := func() (rune, error) {
+		if i < len(runes) && runes[i] == '\\' {
+			i++
+		}
+		if i == len(runes) {
+			return 0, fmt.Errorf("unterminated [ in pattern")
+		}
+		r := runes[i]
+		i++
+		return r, nil
+	}
+	escape := func(r rune) string {
+		if strings.ContainsRune(`\]^-[`, r) {
+			return `\` + string(r)
+		}
+		return string(r)
+	}
+	for first := true; ; first = false {
+		if i == len(runes) {
+			return "", 0, fmt.Errorf("unterminated [ in pattern")
+		}
+		if runes[i] == ']' && !first {
+			break
+		}
+		lo, err := literal()
+		if err != nil {
+			return "", 0, err
+		}
+		if lo == ']' && first {
+			return "", 0, fmt.Errorf("empty [] in pattern")
+		}
+		class.WriteString(escape(lo))
+		if i < len(runes) && runes[i] == '-' {
+			i++
+			hi, err := literal()
+			if err != nil {
+				return "", 0, err
+			}
+			if hi < lo {
+				return "", 0, fmt.Errorf("invalid range %c-%c in pattern", lo, hi)
+			}
+			class.WriteString("-" + escape(hi))
+		}
+	}
+	class.WriteString("]")
+	return class.String(), i + 1, nil
+}
@@ -0,0 +1,161 @@
+package jiraattach
+
+import (
+	"testing"
+)
+
+func TestSemverLess(t *testing.T) {
+	// Each version has lower precedence than the one after it, as in the
+	// example ordering of semver.org.
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"1.10.0",
+		"2.0.0",
+		"10.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			v, ok := parseSemver(ordered[i])
+			if !ok {
+				t.Fatalf("parseSemver(%q) failed", ordered[i])
+			}
+			w, _ := parseSemver(ordered[j])
+			if got, want := v.less(w), i < j; got != want {
+				t.Errorf("%v less than %v = %v, want %v", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		in   string
+		want semver
+		ok   bool
+	}{
+		{in: "1.2.3", want: semver{1, 2, 3, ""}, ok: true},
+		{in: "v1.2.3", want: semver{1, 2, 3, ""}, ok: true},
+		{in: "1.2.3-rc.1", want: semver{1, 2, 3, "rc.1"}, ok: true},
+		{in: "1.2.3+build.5", want: semver{1, 2, 3, ""}, ok: true},
+		{in: "1.2.3-beta+build", want: semver{1, 2, 3, "beta"}, ok: true},
+		{in: "1.2"},
+		{in: "1.2.3.4"},
+		{in: "x1.2.3"},
+		{in: ""},
+	}
+	for _, test := range tests {
+		got, ok := parseSemver(test.in)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseSemver(%q) = %v, %v, want %v, %v", test.in, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestVersionGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		glob    string
+		path    string
+		version string // empty when path doesn't match
+		err     bool
+	}{
+		{pattern: "dist/app-{version}.tar.gz", glob: "dist/app-*.tar.gz", path: "dist/app-1.2.3.tar.gz", version: "1.2.3"},
+		{pattern: "dist/app-{version}.tar.gz", glob: "dist/app-*.tar.gz", path: "dist/app-v2.0.0-rc.1.tar.gz", version: "v2.0.0-rc.1"},
+		{pattern: "dist/app-{version}.tar.gz", glob: "dist/app-*.tar.gz", path: "dist/app-latest.tar.gz"},
+		{pattern: "dist/app-{version}.tar.gz", glob: "dist/app-*.tar.gz", path: "dist/appx1.2.3.tar.gz"},
+		{pattern: "dist/app-{version}-*.zip", glob: "dist/app-*-*.zip", path: "dist/app-1.0.0-linux.zip", version: "1.0.0"},
+		{pattern: "dist/app-{version}-*.zip", glob: "dist/app-*-*.zip", path: "dist/app-1.0.0-linux/x.zip"},
+		{pattern: "dist/app-{version}-?.zip", glob: "dist/app-*-?.zip", path: "dist/app-1.0.0-a.zip", version: "1.0.0"},
+		{pattern: "*/app-{version}.jar", glob: "*/app-*.jar", path: "build/app-3.1.4.jar", version: "3.1.4"},
+		{pattern: "dist/app-{version}-[lw]*.zip", glob: "dist/app-*-[lw]*.zip", path: "dist/app-1.0.0-windows.zip", version: "1.0.0"},
+		{pattern: "dist/app-{version}-[lw]*.zip", glob: "dist/app-*-[lw]*.zip", path: "dist/app-1.0.0-darwin.zip"},
+		{pattern: "dist/app-{version}-[a-m]*.zip", glob: "dist/app-*-[a-m]*.zip", path: "dist/app-1.0.0-linux.zip", version: "1.0.0"},
+		{pattern: "dist/app-{version}-[a-m]*.zip", glob: "dist/app-*-[a-m]*.zip", path: "dist/app-1.0.0-windows.zip"},
+		{pattern: "dist/app-{version}-[^l]*.zip", glob: "dist/app-*-[^l]*.zip", path: "dist/app-1.0.0-darwin.zip", version: "1.0.0"},
+		{pattern: "dist/app-{version}-[^l]*.zip", glob: "dist/app-*-[^l]*.zip", path: "dist/app-1.0.0-linux.zip"},
+		{pattern: "dist/app-{version}[^l]x.zip", glob: "dist/app-*[^l]x.zip", path: "dist/app-1.0.0/x.zip"},
+		{pattern: "dist/app.v{version}[.]zip", glob: "dist/app.v*[.]zip", path: "dist/app.v1.0.0.zip", version: "1.0.0"},
+		{pattern: `dist/app-{version}\*.zip`, glob: `dist/app-*\*.zip`, path: "dist/app-1.0.0*.zip", version: "1.0.0"},
+		{pattern: `dist/app-{version}\*.zip`, glob: `dist/app-*\*.zip`, path: "dist/app-1.0.0x.zip"},
+		{pattern: "dist/app-{version}-[a-z.zip", err: true},
+		{pattern: "dist/app-{version}-[].zip", err: true},
+		{pattern: "dist/app-{version}-[z-a].zip", err: true},
+		{pattern: `dist/app-{version}\`, err: true},
+	}
+	for _, test := range tests {
+		glob, re, err := versionGlob(test.pattern)
+		if test.err {
+			if err == nil {
+				t.Errorf("versionGlob(%q) succeeded, want an error", test.pattern)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("versionGlob(%q): %v", test.pattern, err)
+			continue
+		}
+		if glob != test.glob {
+			t.Errorf("versionGlob(%q) glob = %q, want %q", test.pattern, glob, test.glob)
+		}
+		version := ""
+		if m := re.FindStringSubmatch(test.path); m != nil {
+			version = m[1]
+		}
+		if version != test.version {
+			t.Errorf("versionGlob(%q) matching %q = %q, want %q", test.pattern, test.path, version, test.version)
+		}
+	}
+}
+
+func TestChangelogSection(t *testing.T) {
+	const changelog = `# Changelog
+
+## [Unreleased]
+
+- Work in progress
+
+## [1.2.10] - 2024-03-01
+
+- Tenth patch
+
+## [1.2.1] - 2024-02-01
+
+### Fixed
+
+- A bug
+
+## v1.2.1-rc.1
+
+- Release candidate
+
+## [1.2.0] - 2024-01-01
+
+- First release
+`
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{version: "1.2.1", want: "### Fixed\n\n- A bug"},
+		{version: "v1.2.1", want: "### Fixed\n\n- A bug"},
+		{version: "1.2.10", want: "- Tenth patch"},
+		{version: "1.2.1-rc.1", want: "- Release candidate"},
+		{version: "1.2.0", want: "- First release"},
+		{version: "1.2", want: ""},
+		{version: "3.0.0", want: ""},
+	}
+	for _, test := range tests {
+		if got := changelogSection(changelog, test.version); got != test.want {
+			t.Errorf("changelogSection(%q) = %q, want %q", test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package jiraattach
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const shellHelpMsg = `Commands:
+
+  attach key path      Attach a file to a Jira Issue.
+  list key             List the attachments on a Jira Issue.
+  comment key text...  Add a comment to a Jira Issue.
+  history [pattern]    List uploads made from this machine.
+  help                 Show this message.
+  exit                 Leave the shell.
+
+Aliases from the config file are also available. Run a command with -h
+for its flags and details. Press tab to complete commands, issue keys and
+file paths.
+`
+
+// issueKeyPattern matches Jira issue keys such as PROJ-123.
+var issueKeyPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*-[0-9]+$`)
+
+const shellUsage = `usage: jiraattach shell
+
+  Start an interactive prompt that runs jiraattach commands against a single
+  Jira session, with tab completion of commands, issue keys and paths.
+`
+
+// runShell implements the shell command, an interactive prompt that runs
+// commands against a single Jira session.
+func runShell(config *Config, args []string) error {
+	fs := newFlagSet("shell", shellUsage)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	s := &shell{config: config, keys: map[string]bool{}}
+	var lr lineReader
+	if t, err := newTerminal(os.Stdout, s.complete); err == nil {
+		lr = t
+	} else {
+		lr = &plainReader{out: os.Stdout}
+	}
+
+	for {
+		line, err := lr.readLine("jiraattach> ")
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, redact(err.Error()))
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			fmt.Print(shellHelpMsg)
+			continue
+		case "shell":
+			fmt.Fprintln(os.Stderr, "already in a shell")
+			continue
+		}
+		for _, arg := range args[1:] {
+			if issueKeyPattern.MatchString(arg) {
+				s.keys[arg] = true
+			}
+		}
+		if err := run(config.forCommand(), args); err != nil && err != errUsage && err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, redact(err.Error()))
+		}
+	}
+}
+
+// shell holds the state of an interactive session.
+type shell struct {
+	config *Config
+	keys   map[string]bool
+}
+
+// complete completes the last word of line. It returns the completed line
+// and, when the word is ambiguous, the candidates it could complete to.
+func (s *shell) complete(line string) (string, []string) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	prefix, word := line[:start], line[start:]
+
+	var candidates []string
+	if strings.TrimSpace(prefix) == "" {
+		candidates = append(commandNames(s.config), "help", "exit")
+	} else {
+		for key := range s.keys {
+			candidates = append(candidates, key)
+		}
+		if word != "" && word[0] >= 'A' && word[0] <= 'Z' {
+			candidates = append(candidates, completionKeys(s.config)...)
+		}
+		paths, _ := filepath.Glob(word + "*")
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				path += string(filepath.Separator)
+			}
+			candidates = append(candidates, path)
+		}
+	}
+
+	var matches []string
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) && !seen[c] {
+			seen[c] = true
+			matches = append(matches, c)
+		}
+	}
+	sort.Strings(matches)
+
+	switch len(matches) {
+	case 0:
+		return line, nil
+	case 1:
+		completed := prefix + matches[0]
+		if !strings.HasSuffix(completed, string(filepath.Separator)) {
+			completed += " "
+		}
+		return completed, matches
+	}
+	common := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	return prefix + common, matches
+}
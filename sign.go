@@ -0,0 +1,95 @@
+package jiraattach
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// manifest lists the files attached by a single invocation so their
+// provenance can be verified later against its detached signature.
+type manifest struct {
+	Issue   string         `json:"issue"`
+	Created time.Time      `json:"created"`
+	Files   []manifestFile `json:"files"`
+}
+
+type manifestFile struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// loadSigner reads a PEM encoded RSA, ECDSA or Ed25519 private key.
+func loadSigner(path string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("error reading signing key, %v: no PEM data found", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading signing key, %v: %w", path, err)
+	}
+	switch key := key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key.(crypto.Signer), nil
+	}
+	return nil, fmt.Errorf("error reading signing key, %v: unsupported key type %T", path, key)
+}
+
+// sign returns a detached signature over data. RSA and ECDSA keys sign the
+// SHA-256 digest of data and Ed25519 keys sign data directly, matching what
+// openssl expects when verifying.
+func sign(signer crypto.Signer, data []byte) ([]byte, error) {
+	if _, ok := signer.(ed25519.PrivateKey); ok {
+		return signer.Sign(rand.Reader, data, crypto.Hash(0))
+	}
+	digest := sha256.Sum256(data)
+	return signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+}
+
+// attachManifest signs a manifest of files and attaches it to the issue
+// along with its detached signature.
+func attachManifest(config *Config, key string, signer crypto.Signer, files []manifestFile) error {
+	m := manifest{Issue: key, Created: time.Now().UTC(), Files: files}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding manifest: %w", err)
+	}
+	sig, err := sign(signer, data)
+	if err != nil {
+		return fmt.Errorf("error signing manifest: %w", err)
+	}
+
+	name := "manifest-" + m.Created.Format("20060102T150405Z") + ".json"
+	if _, err := attachFile(config, key, name, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("error uploading %v: %w", name, err)
+	}
+	if _, err := attachFile(config, key, name+".sig", bytes.NewReader(sig)); err != nil {
+		return fmt.Errorf("error uploading %v: %w", name+".sig", err)
+	}
+	return nil
+}
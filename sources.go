@@ -0,0 +1,224 @@
+package jiraattach
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// isRemoteSource reports whether path is a URL to fetch the attachment
+// from rather than a local file.
+func isRemoteSource(path string) bool {
+	lower := strings.ToLower(path)
+	for _, scheme := range []string{"http://", "https://", "s3://", "gs://"} {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteSource is an attachment being streamed from a URL.
+type remoteSource struct {
+	io.ReadCloser
+	name string
+}
+
+// openSource starts fetching the attachment at rawurl once checkSource
+// allows it, and every redirect it follows. The name it is attached as
+// comes from the Content-Disposition header, or else the last element of
+// the URL's path. Query strings, which often carry signatures, are redacted
+// from output. s3:// and gs:// objects are streamed by the aws and gcloud
+// command line tools, which find credentials the usual way.
+func (c *Config) openSource(rawurl string) (*remoteSource, error) {
+	if err := c.checkSource(rawurl); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL %v: %w", rawurl, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "s3", "gs":
+		return openObject(u)
+	}
+	addCredential(u.RawQuery)
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc(c.Proxy, c.ProxyAuth)
+	hc := &http.Client{
+		Transport: t,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return fmt.Errorf("stopped after 10 redirects")
+			}
+			addCredential(req.URL.RawQuery)
+			return c.checkSource(req.URL.String())
+		},
+	}
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source URL %v: %w", rawurl, err)
+	}
+	req = req.WithContext(c.settings.context())
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %v: %w", rawurl, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("error fetching %v: %v", rawurl, resp.Status)
+	}
+	return &remoteSource{ReadCloser: resp.Body, name: sourceName(resp)}, nil
+}
+
+// sourceName returns the filename a fetched attachment is attached as.
+func sourceName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil {
+		if name := sanitizeName(path.Base(strings.Replace(params["filename"], "\\", "/", -1))); name != "" && name != "." && name != "/" {
+			return name
+		}
+	}
+	if name := sanitizeName(path.Base(resp.Request.URL.Path)); name != "" && name != "." && name != "/" {
+		return name
+	}
+	return "download"
+}
+
+// checkSource refuses a remote attachment source unless it matches an entry
+// of allowed_sources, so automation can't be tricked into fetching and
+// attaching internal endpoints. Entries are either URL prefixes such as
+// https://artifacts.example.com/builds/ and s3://bucket/prefix/, or host
+// names such as artifacts.example.com, where *.example.com matches any
+// subdomain. Every source is allowed when allowed_sources isn't set.
+func (c *Config) checkSource(rawurl string) error {
+	if len(c.AllowedSources) == 0 {
+		return nil
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return fmt.Errorf("invalid source URL %v: %w", rawurl, err)
+	}
+	// Compare the parsed form so that tricks such as user info or a
+	// differently cased scheme can't slip past a prefix.
+	normalized := strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range c.AllowedSources {
+		if strings.Contains(allowed, "://") {
+			if u.User == nil && strings.HasPrefix(normalized, allowedPrefix(allowed)) {
+				return nil
+			}
+			continue
+		}
+		allowed = strings.ToLower(allowed)
+		if host == allowed || (strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:])) {
+			return nil
+		}
+	}
+	return fmt.Errorf("source %v://%v%v is not in allowed_sources", u.Scheme, u.Host, u.Path)
+}
+
+// allowedPrefix normalizes a URL prefix from allowed_sources the same way
+// checkSource normalizes sources.
+func allowedPrefix(prefix string) string {
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return prefix
+	}
+	return strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host) + u.EscapedPath()
+}
+
+// validateSources checks that every allowed_sources entry can be parsed.
+func (c *Config) validateSources() error {
+	for _, allowed := range c.AllowedSources {
+		if !strings.Contains(allowed, "://") {
+			continue
+		}
+		if _, err := url.Parse(allowed); err != nil {
+			return fmt.Errorf("invalid allowed_sources entry %v: %w", allowed, err)
+		}
+	}
+	return nil
+}
+
+// openObject starts streaming the S3 or Google Cloud Storage object at u
+// with the aws, gcloud or gsutil command line tool.
+func openObject(u *url.URL) (*remoteSource, error) {
+	if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid source URL %v, expected %v://bucket/key", u, u.Scheme)
+	}
+	object := strings.ToLower(u.Scheme) + "://" + u.Host + u.Path
+	var args []string
+	switch {
+	case strings.ToLower(u.Scheme) == "s3":
+		args = []string{"aws", "s3", "cp", "--only-show-errors", object, "-"}
+	case lookPath("gcloud"):
+		args = []string{"gcloud", "storage", "cat", object}
+	default:
+		args = []string{"gsutil", "cat", object}
+	}
+	if !lookPath(args[0]) {
+		return nil, fmt.Errorf("fetching %v needs the %v command line tool", object, args[0])
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %v: %w", object, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error fetching %v: %w", object, err)
+	}
+	r := &commandReader{r: stdout, cmd: cmd, stderr: stderr, object: object}
+	return &remoteSource{ReadCloser: r, name: sanitizeName(path.Base(u.Path))}, nil
+}
+
+func lookPath(file string) bool {
+	_, err := exec.LookPath(file)
+	return err == nil
+}
+
+// commandReader reads the output of a command fetching object, turning the
+// end of its output into an error when the command fails.
+type commandReader struct {
+	r      io.Reader
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+	object string
+	done   bool
+}
+
+func (c *commandReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err == io.EOF && !c.done {
+		c.done = true
+		if werr := c.cmd.Wait(); werr != nil {
+			return n, c.error(werr)
+		}
+	}
+	return n, err
+}
+
+func (c *commandReader) error(err error) error {
+	if msg := strings.TrimSpace(c.stderr.String()); msg != "" {
+		return fmt.Errorf("error fetching %v: %v", c.object, msg)
+	}
+	return fmt.Errorf("error fetching %v: %w", c.object, err)
+}
+
+// Close stops the command if it is still running.
+func (c *commandReader) Close() error {
+	if !c.done {
+		c.done = true
+		c.cmd.Process.Kill()
+		c.cmd.Wait()
+	}
+	return nil
+}
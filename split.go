@@ -0,0 +1,118 @@
+package jiraattach
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// partName returns the name of part i, counting from 1, of n parts of
+// filename, such as app.log.part01.
+func partName(filename string, i, n int) string {
+	width := len(strconv.Itoa(n))
+	if width < 2 {
+		width = 2
+	}
+	return fmt.Sprintf("%v.part%0*d", filename, width, i)
+}
+
+// attachParts uploads the size bytes read from r to the issue as numbered
+// parts of filename, each no larger than limit, for files larger than Jira
+// accepts whole. The upload is saved as pending once a part is attached.
+func attachParts(config *Config, key, filename string, r io.Reader, size, limit int64) ([]Attachment, error) {
+	ra, offset, cleanup, err := readerAt(r)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	n := int((size + limit - 1) / limit)
+	fmt.Fprintf(os.Stderr, "splitting %v into %d parts of up to %v\n", filename, n, formatSize(limit))
+	var attachments []Attachment
+	p := config.pending
+	if p != nil {
+		switch {
+		case !p.resumed:
+		case p.Filename != filename || p.Parts != n:
+			warnf("the parts of %v attached before don't match how it is split now, attaching all %d parts again", filename, n)
+		default:
+			attachments = p.Attached
+			fmt.Fprintf(os.Stderr, "resuming %v, %d of %d parts already attached\n", filename, len(attachments), n)
+		}
+		p.Filename, p.Parts, p.Attached = filename, n, attachments
+	}
+	for i := len(attachments); i < n; i++ {
+		start := int64(i) * limit
+		length := limit
+		if size-start < length {
+			length = size - start
+		}
+		part, err := upload(config, key, partName(filename, i+1, n), "application/octet-stream", io.NewSectionReader(ra, offset+start, length), length)
+		if err != nil {
+			return attachments, fmt.Errorf("error attaching part %d of %d of %v: %w", i+1, n, filename, err)
+		}
+		attachments = append(attachments, part...)
+		if p != nil {
+			p.Attached = attachments
+			if err := p.save(); err != nil {
+				warnf("unable to record upload of %v: %v", filename, err)
+			}
+		}
+	}
+	return attachments, nil
+}
+
+// readerAt returns r as an io.ReaderAt along with the offset r is at, first
+// copying it to a temporary file, which cleanup removes, when it can't be
+// read at arbitrary offsets.
+func readerAt(r io.Reader) (io.ReaderAt, int64, func(), error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		if rs, ok := r.(io.Seeker); ok {
+			if offset, err := rs.Seek(0, io.SeekCurrent); err == nil {
+				return ra, offset, func() {}, nil
+			}
+		}
+	}
+	tmp, err := ioutil.TempFile("", "jiraattach-")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("error buffering attachment: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("error buffering attachment: %w", err)
+	}
+	return tmp, 0, cleanup, nil
+}
+
+// splitComment explains, in wiki markup, how to put back together the files
+// that were attached as the given groups of parts.
+func splitComment(parts [][]Attachment) string {
+	var lines []string
+	for _, group := range parts {
+		var names []string
+		for _, a := range group {
+			names = append(names, a.Filename)
+		}
+		whole := names[0]
+		if i := strings.LastIndex(whole, ".part"); i > 0 {
+			whole = whole[:i]
+		}
+		lines = append(lines,
+			fmt.Sprintf("%v was too large to attach whole, so it is attached in %d parts. Download them all and join them with:", whole, len(group)),
+			"{noformat}",
+			"cat "+strings.Join(names, " ")+" > "+whole,
+			"{noformat}",
+			"or on Windows:",
+			"{noformat}",
+			"copy /b "+strings.Join(names, "+")+" "+whole,
+			"{noformat}")
+	}
+	return strings.Join(lines, "\n")
+}
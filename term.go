@@ -0,0 +1,171 @@
+package jiraattach
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// stdin is shared by everything that reads interactive input so that input
+// buffered by one reader is not lost to another.
+var stdin = bufio.NewReader(os.Stdin)
+
+// lineReader reads lines of input after displaying a prompt.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines without any editing support. It is used when input
+// does not come from a terminal.
+type plainReader struct {
+	out io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// terminal is a minimal line editor supporting backspace, line kill and tab
+// completion. The terminal is switched out of canonical mode with stty only
+// while a line is being read, so commands run with the terminal in its usual
+// state.
+type terminal struct {
+	in       *bufio.Reader
+	out      io.Writer
+	state    string
+	complete func(line string) (string, []string)
+}
+
+// newTerminal returns a terminal reading from stdin, or an error when stdin is
+// not a terminal that stty can control.
+func newTerminal(out io.Writer, complete func(line string) (string, []string)) (*terminal, error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	return &terminal{
+		in:       stdin,
+		out:      out,
+		state:    strings.TrimSpace(state),
+		complete: complete,
+	}, nil
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func (t *terminal) readLine(prompt string) (string, error) {
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		return "", fmt.Errorf("error configuring terminal: %w", err)
+	}
+	defer stty(t.state)
+
+	fmt.Fprint(t.out, prompt)
+	var line []byte
+	for {
+		b, err := t.in.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		switch b {
+		case '\r', '\n':
+			fmt.Fprint(t.out, "\n")
+			return string(line), nil
+		case 3: // Ctrl-C discards the line
+			fmt.Fprint(t.out, "^C\n"+prompt)
+			line = line[:0]
+		case 4: // Ctrl-D on an empty line ends input
+			if len(line) == 0 {
+				fmt.Fprint(t.out, "\n")
+				return "", io.EOF
+			}
+		case 21: // Ctrl-U kills the line
+			fmt.Fprint(t.out, strings.Repeat("\b \b", utf8.RuneCount(line)))
+			line = line[:0]
+		case 8, 127:
+			if len(line) > 0 {
+				_, size := utf8.DecodeLastRune(line)
+				line = line[:len(line)-size]
+				fmt.Fprint(t.out, "\b \b")
+			}
+		case '\t':
+			if t.complete == nil {
+				continue
+			}
+			completed, candidates := t.complete(string(line))
+			if len(completed) > len(line) {
+				fmt.Fprint(t.out, completed[len(line):])
+				line = []byte(completed)
+			} else if len(candidates) > 1 {
+				fmt.Fprint(t.out, "\n"+strings.Join(candidates, "  ")+"\n"+prompt+string(line))
+			}
+		case 27: // Skip escape sequences such as arrow keys
+			if next, _ := t.in.ReadByte(); next == '[' || next == 'O' {
+				for {
+					c, err := t.in.ReadByte()
+					if err != nil || (c >= 0x40 && c <= 0x7e) {
+						break
+					}
+				}
+			}
+		default:
+			if b < 32 {
+				continue
+			}
+			line = append(line, b)
+			t.out.Write([]byte{b})
+		}
+	}
+}
+
+// confirm asks the user a yes or no question on stderr and reports whether
+// they answered yes. An empty answer selects the default.
+func confirm(question string, def bool) bool {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	fmt.Fprintf(os.Stderr, "%v %v ", question, choices)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// readSecret reads a line from stdin without echoing it when stdin is a
+// terminal, for passwords and tokens.
+func readSecret(prompt string) (string, error) {
+	if state, err := stty("-g"); err == nil {
+		if _, err := stty("-echo"); err == nil {
+			defer func() {
+				stty(strings.TrimSpace(state))
+				fmt.Fprintln(os.Stderr)
+			}()
+		}
+	}
+	line, err := (&plainReader{out: os.Stderr}).readLine(prompt)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
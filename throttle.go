@@ -0,0 +1,96 @@
+package jiraattach
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/bboughton/jiraattach/jira"
+)
+
+// rateLimiter holds uploads to a combined rate, however many are running at
+// once. Each read is given the next slot at that rate, so parallel uploads
+// share the bandwidth between them.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+}
+
+// newRateLimiter returns a limiter allowing rate bytes a second.
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: float64(rate)}
+}
+
+// chunk is the most a single read takes at once, a tenth of a second's
+// worth, so the rate stays smooth.
+func (l *rateLimiter) chunk() int {
+	n := int(l.rate / 10)
+	if n < 512 {
+		n = 512
+	}
+	return n
+}
+
+// wait blocks until n more bytes may be sent, or ctx is done. Time not used
+// while idle isn't saved up, so uploads never burst above the rate.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader reads through a rateLimiter.
+type throttledReader struct {
+	r   io.Reader
+	l   *rateLimiter
+	ctx context.Context
+	n   int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if max := t.l.chunk(); len(p) > max {
+		p = p[:max]
+	}
+	n, err := t.r.Read(p)
+	t.n += int64(n)
+	if n > 0 {
+		if werr := t.l.wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Rewind lets a throttled upload be retried.
+func (t *throttledReader) Rewind() error {
+	if err := jira.Rewind(t.r, t.n); err != nil {
+		return err
+	}
+	t.n = 0
+	return nil
+}
+
+// throttle returns r limited to the configured limit_rate, or r itself when
+// there is no limit.
+func (c *Config) throttle(r io.Reader) io.Reader {
+	if c.limiter == nil {
+		return r
+	}
+	return &throttledReader{r: r, l: c.limiter, ctx: c.settings.context()}
+}
@@ -0,0 +1,96 @@
+package jiraattach
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSConfig holds the TLS settings for connecting to Jira, for instances
+// whose certificates are issued by an internal CA or that require client
+// certificates.
+type TLSConfig struct {
+	// CAFile is a PEM bundle of CA certificates trusted along with the
+	// system's.
+	CAFile string `json:"ca_file"`
+
+	// CertFile and KeyFile are a PEM client certificate and its key,
+	// presented to servers that ask for one. The key may be in CertFile.
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+
+	// MinVersion is the oldest TLS version accepted, 1.0 to 1.3.
+	MinVersion string `json:"min_version"`
+
+	// Insecure skips verifying the server's certificate.
+	Insecure bool `json:"insecure"`
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// clientConfig returns the tls.Config for the settings, or nil when none
+// are set.
+func (c TLSConfig) clientConfig() (*tls.Config, error) {
+	if c == (TLSConfig{}) {
+		return nil, nil
+	}
+	conf := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls ca_file %v has no PEM certificates", c.CAFile)
+		}
+		conf.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		key := c.KeyFile
+		if key == "" {
+			key = c.CertFile
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, key)
+		if err != nil {
+			return nil, fmt.Errorf("error loading tls client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	} else if c.KeyFile != "" {
+		return nil, fmt.Errorf("tls key_file needs cert_file")
+	}
+	if c.MinVersion != "" {
+		v, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls min_version %q, expected 1.0, 1.1, 1.2 or 1.3", c.MinVersion)
+		}
+		conf.MinVersion = v
+	}
+	return conf, nil
+}
+
+// override applies the TLS flags, which take precedence over the config.
+func (c *TLSConfig) override(s *settings) {
+	if s.cacert != "" {
+		c.CAFile = s.cacert
+	}
+	if s.cert != "" {
+		c.CertFile = s.cert
+		c.KeyFile = s.key
+	} else if s.key != "" {
+		c.KeyFile = s.key
+	}
+	if s.tlsmin != "" {
+		c.MinVersion = s.tlsmin
+	}
+	c.Insecure = c.Insecure || s.tlsinsecure
+}
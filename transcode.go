@@ -0,0 +1,78 @@
+package jiraattach
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// TranscodeConfig configures re-encoding of screen recordings with ffmpeg
+// before they are attached.
+type TranscodeConfig struct {
+	// Enabled turns transcoding on for every video attached. It can also be
+	// turned on for a single run with -transcode.
+	Enabled bool `json:"enabled"`
+
+	// FFmpeg is the path to the ffmpeg executable, found on the PATH by
+	// default.
+	FFmpeg string `json:"ffmpeg"`
+
+	// Height is the maximum height of the output in pixels, 720 by default.
+	// Smaller videos are not scaled up.
+	Height int `json:"height"`
+
+	// CRF is the H.264 constant rate factor, 28 by default. Higher values
+	// produce smaller files of lower quality.
+	CRF int `json:"crf"`
+}
+
+// videoExtensions lists the file extensions treated as videos.
+var videoExtensions = map[string]bool{".webm": true, ".mp4": true, ".mov": true, ".mkv": true, ".avi": true}
+
+// transcode re-encodes the video at path as H.264 MP4 into a temporary file
+// when transcoding is enabled. It returns the path and name to upload, which
+// are unchanged for files that aren't videos, and a function removing any
+// temporary file.
+func (c TranscodeConfig) transcode(path, name string) (string, string, func(), error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if !c.Enabled || !videoExtensions[ext] {
+		return path, name, func() {}, nil
+	}
+	ffmpeg, height, crf := c.FFmpeg, c.Height, c.CRF
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	if height == 0 {
+		height = 720
+	}
+	if crf == 0 {
+		crf = 28
+	}
+
+	dir, err := ioutil.TempDir("", "jiraattach-")
+	if err != nil {
+		return "", "", nil, fmt.Errorf("error transcoding %v: %w", path, err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	out := filepath.Join(dir, "video.mp4")
+	cmd := exec.Command(ffmpeg, "-nostdin", "-loglevel", "error", "-i", path,
+		"-vf", fmt.Sprintf("scale=-2:'min(%d,ih)'", height),
+		"-c:v", "libx264", "-preset", "veryfast", "-crf", strconv.Itoa(crf), "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-movflags", "+faststart", out)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		cleanup()
+		return "", "", nil, fmt.Errorf("error transcoding %v: %w", path, err)
+	}
+
+	if before, err := os.Stat(path); err == nil {
+		if after, err := os.Stat(out); err == nil {
+			fmt.Fprintf(os.Stderr, "transcoded %v: %v -> %v\n", path, formatSize(before.Size()), formatSize(after.Size()))
+		}
+	}
+	return out, strings.TrimSuffix(name, filepath.Ext(name)) + ".mp4", cleanup, nil
+}
@@ -0,0 +1,231 @@
+package jiraattach
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeoutConfig limits how long each stage of a request may take. Nothing
+// limits a request as a whole, since uploading a large file over a slow
+// link can rightly take a long time; -timeout limits a whole command.
+type TimeoutConfig struct {
+	// Connect limits connecting to Jira, or the proxy, and the TLS
+	// handshake each.
+	Connect duration `json:"connect"`
+	// ResponseHeader limits the wait for a response once a request,
+	// including any upload, has been sent.
+	ResponseHeader duration `json:"response_header"`
+	// Idle is how long an unused connection is kept open for reuse.
+	Idle duration `json:"idle"`
+}
+
+// defaultTimeouts apply to the timeouts that aren't configured.
+var defaultTimeouts = TimeoutConfig{
+	Connect:        duration{30 * time.Second},
+	ResponseHeader: duration{2 * time.Minute},
+	Idle:           duration{90 * time.Second},
+}
+
+func (c TimeoutConfig) validate() error {
+	for name, d := range map[string]duration{"connect": c.Connect, "response_header": c.ResponseHeader, "idle": c.Idle} {
+		if d.Duration < 0 {
+			return fmt.Errorf("invalid %v timeout %v", name, d.Duration)
+		}
+	}
+	return nil
+}
+
+// withDefaults fills in the timeouts that aren't configured.
+func (c TimeoutConfig) withDefaults() TimeoutConfig {
+	if c.Connect.Duration == 0 {
+		c.Connect = defaultTimeouts.Connect
+	}
+	if c.ResponseHeader.Duration == 0 {
+		c.ResponseHeader = defaultTimeouts.ResponseHeader
+	}
+	if c.Idle.Duration == 0 {
+		c.Idle = defaultTimeouts.Idle
+	}
+	return c
+}
+
+// newTransport returns the HTTP transport used to talk to Jira, configured
+// with the proxy settings from config and the environment.
+func newTransport(config *Config) *http.Transport {
+	timeouts := config.Timeouts.withDefaults()
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = proxyFunc(config.Proxy, config.ProxyAuth)
+	t.TLSHandshakeTimeout = timeouts.Connect.Duration
+	t.ResponseHeaderTimeout = timeouts.ResponseHeader.Duration
+	t.IdleConnTimeout = timeouts.Idle.Duration
+	if conf, err := config.TLS.clientConfig(); err != nil {
+		warnf("%v", err)
+	} else if conf != nil {
+		t.TLSClientConfig = conf
+	}
+	dialer := &net.Dialer{Timeout: timeouts.Connect.Duration, KeepAlive: 30 * time.Second}
+	t.DialContext = dialer.DialContext
+	if config.Dial != "" {
+		network, target, _ := parseDial(config.Dial)
+		t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, target)
+		}
+	} else if overrides, _ := parseResolve(config.Resolve); len(overrides) > 0 {
+		t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if override, ok := overrides[addr]; ok {
+				addr = override
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
+	}
+	return t
+}
+
+// parseDial parses a dial target, either unix:///path/to.sock for a unix
+// socket or host:port, such as the local end of an SSH port forward. Every
+// connection is made to the target, while the Host header and TLS server
+// name still come from the Jira URL.
+func parseDial(dial string) (network, addr string, err error) {
+	if strings.HasPrefix(dial, "unix://") {
+		return "unix", strings.TrimPrefix(dial, "unix://"), nil
+	}
+	if _, _, err := net.SplitHostPort(dial); err != nil {
+		return "", "", fmt.Errorf("invalid dial target %q, expected host:port or unix:///path", dial)
+	}
+	return "tcp", dial, nil
+}
+
+// parseResolve parses curl style host overrides of the form
+// host:port:address into a map from host:port to the address:port to dial
+// instead. TLS and the Host header still use the original host name.
+func parseResolve(entries []string) (map[string]string, error) {
+	overrides := map[string]string{}
+	for _, entry := range entries {
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid resolve entry %q, expected host:port:address", entry)
+		}
+		host, port, addr := parts[0], parts[1], strings.Trim(parts[2], "[]")
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid resolve entry %q, bad port %v", entry, port)
+		}
+		if net.ParseIP(addr) == nil {
+			return nil, fmt.Errorf("invalid resolve entry %q, bad address %v", entry, addr)
+		}
+		overrides[net.JoinHostPort(host, port)] = net.JoinHostPort(addr, port)
+	}
+	return overrides, nil
+}
+
+// proxyFunc returns the proxy selection function for the transport. An
+// explicitly configured proxy is always used. Otherwise the standard
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables are honored, falling back
+// to ALL_PROXY, which is how SOCKS proxies such as an SSH dynamic port
+// forward are usually configured. auth, as user:password, authenticates
+// with whichever proxy is used unless its URL has credentials of its own.
+func proxyFunc(proxy, auth string) func(*http.Request) (*url.URL, error) {
+	var user *url.Userinfo
+	if auth != "" {
+		name, pass, _, _ := parseAuth(authBasic, auth)
+		addBasicAuth(name, pass)
+		user = url.UserPassword(name, pass)
+	}
+	withAuth := func(u *url.URL, err error) (*url.URL, error) {
+		if u == nil || err != nil {
+			return u, err
+		}
+		if pass, ok := u.User.Password(); ok {
+			addBasicAuth(u.User.Username(), pass)
+		} else if user != nil {
+			withUser := *u
+			withUser.User = user
+			u = &withUser
+		}
+		return u, nil
+	}
+	if proxy != "" {
+		u, err := withAuth(parseProxyURL(proxy))
+		return func(*http.Request) (*url.URL, error) {
+			return u, err
+		}
+	}
+	all := getenvAny("ALL_PROXY", "all_proxy")
+	return func(req *http.Request) (*url.URL, error) {
+		u, err := http.ProxyFromEnvironment(req)
+		if u != nil || err != nil || all == "" {
+			return withAuth(u, err)
+		}
+		host := req.URL.Hostname()
+		if host == "localhost" || isLoopback(host) || noProxy(host, getenvAny("NO_PROXY", "no_proxy")) {
+			return nil, nil
+		}
+		return withAuth(parseProxyURL(all))
+	}
+}
+
+// parseProxyURL parses a proxy address. Addresses without a scheme are
+// treated as HTTP proxies. SOCKS5 proxies use the socks5 scheme, or
+// socks5h to have the proxy resolve host names, which net/http always does.
+func parseProxyURL(proxy string) (*url.URL, error) {
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %v: %w", proxy, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	case "socks5h":
+		u.Scheme = "socks5"
+	default:
+		return nil, fmt.Errorf("invalid proxy %v: unsupported scheme %v", proxy, u.Scheme)
+	}
+	return u, nil
+}
+
+// noProxy reports whether host is excluded from proxying by a NO_PROXY
+// style list of host names, domain suffixes and IP addresses.
+func noProxy(host, list string) bool {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if h, _, err := net.SplitHostPort(entry); err == nil {
+			entry = h
+		}
+		entry = strings.TrimPrefix(entry, "*")
+		if host == strings.TrimPrefix(entry, ".") || (strings.HasPrefix(entry, ".") && strings.HasSuffix(host, entry)) {
+			return true
+		}
+		if !strings.HasPrefix(entry, ".") && strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func getenvAny(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
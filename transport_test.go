@@ -0,0 +1,60 @@
+package jiraattach
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "none", want: map[string]string{}},
+		{
+			name:    "IPv4",
+			entries: []string{"jira.example.com:443:10.0.0.5"},
+			want:    map[string]string{"jira.example.com:443": "10.0.0.5:443"},
+		},
+		{
+			name:    "IPv6 in brackets",
+			entries: []string{"jira.example.com:443:[2001:db8::1]"},
+			want:    map[string]string{"jira.example.com:443": "[2001:db8::1]:443"},
+		},
+		{
+			name:    "IPv6 without brackets",
+			entries: []string{"jira.example.com:8443:::1"},
+			want:    map[string]string{"jira.example.com:8443": "[::1]:8443"},
+		},
+		{
+			name:    "several",
+			entries: []string{"a.example.com:443:10.0.0.1", "b.example.com:80:10.0.0.2"},
+			want:    map[string]string{"a.example.com:443": "10.0.0.1:443", "b.example.com:80": "10.0.0.2:80"},
+		},
+		{name: "missing address", entries: []string{"jira.example.com:443"}, wantErr: true},
+		{name: "empty address", entries: []string{"jira.example.com:443:"}, wantErr: true},
+		{name: "empty host", entries: []string{":443:10.0.0.5"}, wantErr: true},
+		{name: "bad port", entries: []string{"jira.example.com:https:10.0.0.5"}, wantErr: true},
+		{name: "port out of range", entries: []string{"jira.example.com:70000:10.0.0.5"}, wantErr: true},
+		{name: "host name as address", entries: []string{"jira.example.com:443:other.example.com"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := parseResolve(test.entries)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("parseResolve(%q) = %v, want an error", test.entries, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseResolve(%q): %v", test.entries, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseResolve(%q) = %v, want %v", test.entries, got, test.want)
+			}
+		})
+	}
+}